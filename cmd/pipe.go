@@ -16,7 +16,12 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/potterxu/tsanalyzer/internal/cell"
 	"github.com/potterxu/tsanalyzer/internal/graph"
@@ -24,19 +29,28 @@ import (
 )
 
 var (
-	pipeFullHelpFlag bool   = false
-	pipeListCellFlag bool   = false
-	pipeCellHelp     string = ""
+	pipeFullHelpFlag bool          = false
+	pipeListCellFlag bool          = false
+	pipeCellHelp     string        = ""
+	pipeFile         string        = ""
+	pipeFailFast     bool          = false
+	pipeDrainTimeout time.Duration = 5 * time.Second
+	pipeStats        bool          = false
+	pipeStatsPeriod  time.Duration = 5 * time.Second
+	pipeDot          bool          = false
+	pipeMermaid      bool          = false
+	pipeCheck        bool          = false
 )
 
 // pipeCmd represents the pipe command
 var pipeCmd = &cobra.Command{
-	Use:   "pipe",
+	Use:   "pipe [-F pipeline.yaml]",
 	Short: "Use pipeline to process stream",
 
 	Run: func(cmd *cobra.Command, args []string) {
 		runPipe(args)
 	},
+	ValidArgsFunction: completePipe,
 }
 
 func init() {
@@ -45,6 +59,15 @@ func init() {
 	pipeCmd.PersistentFlags().BoolVarP(&pipeFullHelpFlag, "full", "f", false, "full help for cells")
 	pipeCmd.PersistentFlags().BoolVarP(&pipeListCellFlag, "list", "l", false, "list all cells")
 	pipeCmd.PersistentFlags().StringVarP(&pipeCellHelp, "cell", "c", "", "help for specific cell")
+	pipeCmd.PersistentFlags().StringVarP(&pipeFile, "file", "F", "", "load pipeline from YAML/JSON file instead of the arguments")
+	pipeCmd.PersistentFlags().BoolVar(&pipeFailFast, "fail-fast", false, "stop the whole pipeline when any cell fails")
+	pipeCmd.PersistentFlags().BoolVar(&pipeStats, "stats", false, "print the cell and edge metrics periodically and when finished")
+	pipeCmd.PersistentFlags().DurationVar(&pipeStatsPeriod, "stats-interval", pipeStatsPeriod, "interval of the periodic metrics, 0 to print only when finished")
+	pipeCmd.PersistentFlags().BoolVar(&pipeDot, "dot", false, "print the resolved pipeline as Graphviz DOT without running it")
+	pipeCmd.PersistentFlags().BoolVar(&pipeMermaid, "mermaid", false, "print the resolved pipeline as Mermaid flowchart without running it")
+	pipeCmd.PersistentFlags().BoolVar(&pipeCheck, "check", false, "validate the cells, connections and resources without processing any data")
+	pipeCmd.MarkFlagsMutuallyExclusive("dot", "mermaid", "check")
+	pipeCmd.PersistentFlags().DurationVar(&pipeDrainTimeout, "drain-timeout", pipeDrainTimeout, "time to drain the pipeline after Ctrl-C before aborting, 0 to wait forever")
 }
 
 func runPipe(args []string) {
@@ -53,19 +76,164 @@ func runPipe(args []string) {
 		return
 	}
 
-	if pipeListCellFlag || args == nil || len(args) == 0 {
+	if len(pipeCellHelp) > 0 {
+		cell.CellHelper(pipeCellHelp)
+		return
+	}
+
+	if pipeListCellFlag || (len(args) == 0 && pipeFile == "") {
 		cell.PrintCells()
 		return
 	}
 
-	if len(pipeCellHelp) > 0 {
-		cell.CellHelper(pipeCellHelp)
+	if pipeFile != "" && len(args) > 0 {
+		fmt.Printf("pipeline given both by -F %v and as arguments: %v\n", pipeFile, strings.Join(args, " "))
+		os.Exit(1)
+	}
+
+	if pipeCheck {
+		checkPipe(args)
+		return
+	}
+
+	export := ""
+	if pipeDot {
+		export = graph.EXPORT_DOT
+	} else if pipeMermaid {
+		export = graph.EXPORT_MERMAID
+	}
+	log := os.Stdout
+	if export != "" {
+		// keep the build messages out of the diagram
+		log = os.Stderr
+	}
+	var g graph.Graph
+	var err error
+	if pipeFile != "" {
+		g, err = graph.NewGraphFromFile(pipeFile, log)
+	} else {
+		g, err = graph.NewGraph(strings.Join(args, " "), log)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if export != "" {
+		if err := g.Export(os.Stdout, export); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 		return
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handleInterrupt(ctx, g, cancel)
+	if pipeStats {
+		go printStats(ctx, g, pipeStatsPeriod)
+	}
 
-	g, err := graph.NewGraph(strings.Join(args, " "))
+	opts := graph.Options{
+		FailFast:     pipeFailFast,
+		DrainTimeout: pipeDrainTimeout,
+	}
+	err = g.Run(ctx, opts)
+	cancel()
+	if pipeStats {
+		fmt.Println("Pipeline stats:")
+		g.Stats().Print(os.Stdout)
+	}
 	if err != nil {
-		panic(err)
+		fmt.Println("Pipeline failed:")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// checkPipe reports every problem of the pipeline and exits non-zero if any
+func checkPipe(args []string) {
+	var err error
+	if pipeFile != "" {
+		err = graph.CheckGraphFile(pipeFile, os.Stdout)
+	} else {
+		err = graph.CheckGraph(strings.Join(args, " "), os.Stdout)
+	}
+	if err != nil {
+		fmt.Println("Pipeline check failed:")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("Pipeline check passed")
+}
+
+// handleInterrupt drains the graph on the first Ctrl-C and aborts it on the second
+func handleInterrupt(ctx context.Context, g graph.Graph, cancel context.CancelFunc) {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt)
+	defer signal.Stop(signalChan)
+
+	select {
+	case <-signalChan:
+		fmt.Println("Interrupted, draining the pipeline (Ctrl-C again to abort)")
+		g.Stop()
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-signalChan:
+		fmt.Println("Aborting the pipeline")
+		cancel()
+	case <-ctx.Done():
+	}
+}
+
+// printStats prints the metrics of the running graph every interval
+func printStats(ctx context.Context, g graph.Graph, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fmt.Println("Pipeline stats:")
+			g.Stats().Print(os.Stdout)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// completePipe completes cell names after "!", and properties of the current cell
+func completePipe(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	current := ""
+	for _, arg := range args {
+		switch {
+		case arg == "!":
+			current = ""
+		case current == "" && cell.Exists(arg):
+			current = arg
+		}
+	}
+	if current == "" {
+		return cell.Names(), cobra.ShellCompDirectiveNoFileComp
+	}
+
+	spec, _ := cell.GetSpec(current)
+	if key, _, ok := strings.Cut(toComplete, "="); ok {
+		// complete the value of a property
+		if p, ok := spec.Schema.Find(key); ok && len(p.Enum) > 0 {
+			values := make([]string, len(p.Enum))
+			for i, v := range p.Enum {
+				values[i] = key + "=" + v
+			}
+			return values, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	completions := []string{"!"}
+	for _, p := range spec.Schema {
+		completions = append(completions, p.Name+"=\t"+p.Description)
 	}
-	g.Run()
+	return completions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
 }
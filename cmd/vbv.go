@@ -19,7 +19,7 @@ var (
 // vbvCmd represents the vbv command
 var vbvCmd = &cobra.Command{
 	Use:   "vbv <filename>",
-	Short: "Calculate for vbv, alias for pipe [file_reader ! bytes_converter ! vbv]",
+	Short: "Calculate for vbv, alias for pipe [file_reader ! vbv]",
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) != 1 {
 			if err := cmd.Help(); err != nil {
@@ -28,7 +28,7 @@ var vbvCmd = &cobra.Command{
 			return
 		}
 		filename := args[0]
-		pipe := fmt.Sprintf("file_reader name=%v ! bytes_converter output_format=ts_packet ! vbv pcr=%v pids=%v dir=%v plot=%v",
+		pipe := fmt.Sprintf("file_reader name=%v ! vbv pcr=%v pids=%v dir=%v plot=%v",
 			filename, pcrPID, streamPIDs, fmt.Sprintf("%v.log", filename), plot)
 		pipeArgs := strings.Split(pipe, " ")
 		pipeCmd.Run(nil, pipeArgs)
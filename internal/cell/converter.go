@@ -0,0 +1,67 @@
+package cell
+
+import (
+	"slices"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+)
+
+// ConverterStep describes a converter cell to insert between incompatible cells
+type ConverterStep struct {
+	Name   string
+	Config icell.Config
+	From   icell.Format
+	To     icell.Format
+}
+
+var (
+	converters = []*ConverterStep{}
+)
+
+// RegisterConverter registers a cell able to convert from one format to another,
+// the cell is inserted automatically between incompatible cells
+func RegisterConverter(name string, from, to icell.Format, config icell.Config) {
+	registry.Lock()
+	defer registry.Unlock()
+	converters = append(converters, &ConverterStep{
+		Name:   name,
+		Config: config,
+		From:   from,
+		To:     to,
+	})
+}
+
+/* FindConverterPath searches the shortest chain of registered converters
+ * which converts any of the src formats into any of the dst formats
+ * return false if no such chain exists
+ */
+func FindConverterPath(src, dst []icell.Format) ([]*ConverterStep, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	type node struct {
+		format icell.Format
+		path   []*ConverterStep
+	}
+	visited := make(map[icell.Format]bool)
+	queue := make([]node, 0, len(src))
+	for _, f := range src {
+		visited[f] = true
+		queue = append(queue, node{format: f})
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, step := range converters {
+			if step.From != cur.format || visited[step.To] {
+				continue
+			}
+			path := append(slices.Clone(cur.path), step)
+			if slices.Contains(dst, step.To) {
+				return path, true
+			}
+			visited[step.To] = true
+			queue = append(queue, node{format: step.To, path: path})
+		}
+	}
+	return nil, false
+}
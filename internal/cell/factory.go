@@ -2,59 +2,204 @@ package cell
 
 import (
 	"fmt"
+	"slices"
+	"sync"
 
 	"github.com/potterxu/tsanalyzer/internal/cell/icell"
 	"github.com/potterxu/tsanalyzer/internal/errinfo"
 )
 
-type cellType int
+// CellType is the category of the cell shown in the help
+type CellType int
 
 const (
-	type_reader = iota
-	type_converter
-	type_processor
-	type_writer
+	TYPE_READER CellType = iota
+	TYPE_CONVERTER
+	TYPE_PROCESSOR
+	TYPE_WRITER
 )
 
-type cell_ctor func(chan bool, icell.Config) (icell.ICell, error)
+func (t CellType) String() string {
+	switch t {
+	case TYPE_READER:
+		return "reader"
+	case TYPE_CONVERTER:
+		return "converter"
+	case TYPE_PROCESSOR:
+		return "processor"
+	case TYPE_WRITER:
+		return "writer"
+	}
+	return fmt.Sprintf("CellType(%d)", int(t))
+}
+
+// Constructor creates the cell from its config
+type Constructor func(icell.Config) (icell.ICell, error)
 type cell_short func()
 type cell_help func()
 
+// Registration describes a cell to the factory
+type Registration struct {
+	Type  CellType
+	Name  string // name used in the pipe syntax
+	Ctor  Constructor
+	Spec  icell.Spec // description, ports and properties for help and completion
+	Short func()     // optional, overrides the one line description generated from Spec
+	Help  func()     // optional, overrides the full help generated from Spec
+}
+
 type factory struct {
-	ctor      cell_ctor
+	typ       CellType
+	ctor      Constructor
+	spec      icell.Spec
 	shortHelp cell_short
 	help      cell_help
 }
 
 var (
-	cells     = map[cellType][]string{}
+	// registry guards cells, factories and converters, cells may be registered while pipelines are built
+	registry  sync.RWMutex
+	cells     = map[CellType][]string{}
 	factories = map[string]*factory{}
 )
 
-func NewCell(name string, stopChan chan bool, config map[string]string) (icell.ICell, error) {
-	if factory, ok := factories[name]; ok {
-		return factory.ctor(stopChan, config)
+/* Register makes the cell available to the pipe syntax and help,
+ * cells outside this module should be registered in the init() of their package,
+ * it is safe to call concurrently with building pipelines
+ * return error if the name is already registered
+ */
+func Register(r Registration) error {
+	if r.Name == "" || r.Ctor == nil {
+		return fmt.Errorf("%w: name and constructor required", errinfo.ErrInvalidRegistration)
+	}
+	if r.Type < TYPE_READER || r.Type > TYPE_WRITER {
+		return fmt.Errorf("%w: invalid type %v for %v", errinfo.ErrInvalidRegistration, r.Type, r.Name)
+	}
+	short := r.Short
+	if short == nil {
+		short = func() {
+			printShort(r.Name, &r.Spec)
+		}
+	}
+	help := r.Help
+	if help == nil {
+		help = func() {
+			short()
+			printSpec(&r.Spec)
+		}
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := factories[r.Name]; ok {
+		return fmt.Errorf("%w: %v already registered", errinfo.ErrInvalidRegistration, r.Name)
+	}
+	cells[r.Type] = append(cells[r.Type], r.Name)
+	factories[r.Name] = &factory{r.Type, r.Ctor, r.Spec, short, help}
+	return nil
+}
+
+func lookup(name string) (*factory, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	f, ok := factories[name]
+	return f, ok
+}
+
+// cellsOf returns a copy of the names of the cells of the type
+func cellsOf(t CellType) []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	return slices.Clone(cells[t])
+}
+
+func NewCell(name string, config map[string]string) (icell.ICell, error) {
+	if factory, ok := lookup(name); ok {
+		return factory.ctor(config)
 	}
 	return nil, errinfo.ErrCellNotSupport
 }
 
+// Exists reports whether the cell is registered
+func Exists(name string) bool {
+	_, ok := lookup(name)
+	return ok
+}
+
+// GetSpec returns the spec of a registered cell
+func GetSpec(name string) (icell.Spec, bool) {
+	if factory, ok := lookup(name); ok {
+		return factory.spec, true
+	}
+	return icell.Spec{}, false
+}
+
+// GetType returns the type of a registered cell
+func GetType(name string) (CellType, bool) {
+	if factory, ok := lookup(name); ok {
+		return factory.typ, true
+	}
+	return 0, false
+}
+
+// Names returns the names of all registered cells
+func Names() []string {
+	names := make([]string, 0)
+	for _, t := range []CellType{TYPE_READER, TYPE_CONVERTER, TYPE_PROCESSOR, TYPE_WRITER} {
+		names = append(names, cellsOf(t)...)
+	}
+	return names
+}
+
+func printShort(name string, spec *icell.Spec) {
+	fmt.Printf("%v : %v\n", name, spec.Description)
+}
+
+func printPorts(arrow string, ports []icell.PortSpec) {
+	if len(ports) == 0 {
+		fmt.Printf(arrow+": %v\n", "", []icell.Format(nil))
+		return
+	}
+	for _, p := range ports {
+		name := ""
+		if len(ports) > 1 {
+			name = "." + p.Name
+		}
+		fmt.Printf(arrow+": %v\n", name, p.Formats)
+	}
+}
+
+func printSpec(spec *icell.Spec) {
+	fmt.Println("\tIO:")
+	printPorts("\t  ->cell%v", spec.Inputs)
+	printPorts("\t  cell%v->", spec.Outputs)
+	fmt.Println("\tProperties:")
+	if len(spec.Schema) == 0 {
+		fmt.Println("\t  none")
+	}
+	for i := range spec.Schema {
+		fmt.Printf("\t  %v\n", spec.Schema[i].Usage())
+	}
+}
+
 func printCategoryShort(category string, cells []string) {
 	if len(cells) < 1 {
 		return
 	}
 	fmt.Printf("--- %v ---\n", category)
 	for _, name := range cells {
-		factories[name].shortHelp()
+		if factory, ok := lookup(name); ok {
+			factory.shortHelp()
+		}
 	}
 	fmt.Println()
 }
 func PrintCells() {
 	printSyntax()
 	fmt.Println("=== Available Cells ===")
-	printCategoryShort("readers", cells[type_reader])
-	printCategoryShort("converters", cells[type_converter])
-	printCategoryShort("processors", cells[type_processor])
-	printCategoryShort("writers", cells[type_writer])
+	printCategoryShort("readers", cellsOf(TYPE_READER))
+	printCategoryShort("converters", cellsOf(TYPE_CONVERTER))
+	printCategoryShort("processors", cellsOf(TYPE_PROCESSOR))
+	printCategoryShort("writers", cellsOf(TYPE_WRITER))
 }
 
 func printCategory(category string, cells []string) {
@@ -63,21 +208,23 @@ func printCategory(category string, cells []string) {
 	}
 	fmt.Printf("--- %v ---\n", category)
 	for _, name := range cells {
-		factories[name].help()
+		if factory, ok := lookup(name); ok {
+			factory.help()
+		}
 	}
 	fmt.Println()
 }
 func Help() {
 	printSyntax()
 	fmt.Println("===Cell Help===")
-	printCategory("readers", cells[type_reader])
-	printCategory("converters", cells[type_converter])
-	printCategory("processors", cells[type_processor])
-	printCategory("writers", cells[type_writer])
+	printCategory("readers", cellsOf(TYPE_READER))
+	printCategory("converters", cellsOf(TYPE_CONVERTER))
+	printCategory("processors", cellsOf(TYPE_PROCESSOR))
+	printCategory("writers", cellsOf(TYPE_WRITER))
 }
 
 func CellHelper(name string) {
-	if factory, ok := factories[name]; ok {
+	if factory, ok := lookup(name); ok {
 		fmt.Printf("===Help for cell [%v]===\n", name)
 		factory.help()
 	} else {
@@ -89,4 +236,6 @@ func printSyntax() {
 	fmt.Println()
 	fmt.Println("Syntax: pipe cell prop1=val1 prop2=val2 ! cell2 prop1=val1 prop2=val2 ! ...")
 	fmt.Println()
+	fmt.Println("Queue:  pipe cell ! queue capacity=N policy=block|drop-newest|drop-oldest ! cell2")
+	fmt.Println()
 }
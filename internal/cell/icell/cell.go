@@ -1,10 +1,15 @@
 package icell
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"slices"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
 )
 
 // Every customized Cell should composite Cell struct
@@ -12,80 +17,236 @@ import (
 type Cell struct {
 	ICell
 
-	id       string
-	running  bool
-	stopChan chan bool
+	id      string
+	ctx     context.Context // canceled to abort the cell immediately
+	stopped atomic.Bool     // set to stop producing gracefully
 
-	// pipeline usage
-	input  *Edge
-	output *Edge
+	// pipeline usage, declared by the customized cell
+	inputs  []*port
+	outputs []*port // every edge of an output port receives the same units (tee)
+
+	// metrics, in nanoseconds
+	started    atomic.Int64
+	finished   atomic.Int64
+	inputWait  atomic.Int64
+	outputWait atomic.Int64
 }
 
 type Config map[string]string
 
 const (
-	config_cel_id = "id"
+	CONFIG_CELL_ID = "id"
 )
 
 // Default interface method
 func (c *Cell) Id() string {
 	return c.id
 }
-func (c *Cell) Connect(next ICell) error {
-	e, err := newEdge(c.ICell, next)
+func (c *Cell) InputPorts() []string {
+	return portNames(c.inputs)
+}
+func (c *Cell) OutputPorts() []string {
+	return portNames(c.outputs)
+}
+func (c *Cell) InputFormats(port string) ([]Format, error) {
+	p, err := findPort(c.inputs, port)
+	if err != nil {
+		return nil, err
+	}
+	return p.formats, nil
+}
+func (c *Cell) OutputFormats(port string) ([]Format, error) {
+	p, err := findPort(c.outputs, port)
+	if err != nil {
+		return nil, err
+	}
+	return p.formats, nil
+}
+func (c *Cell) Connect(port string, next ICell, nextPort string, opts EdgeOptions) error {
+	srcFormats, err := c.OutputFormats(port)
 	if err != nil {
 		return err
 	}
-	c.SetOutput(e)
-	next.SetInput(e)
-	return nil
+	dstFormats, err := next.InputFormats(nextPort)
+	if err != nil {
+		return err
+	}
+	e, err := newEdge(c.ICell, port, next, nextPort, srcFormats, dstFormats, opts)
+	if err != nil {
+		return err
+	}
+	if err := c.SetOutput(port, e); err != nil {
+		return err
+	}
+	return next.SetInput(nextPort, e)
 }
-func (c *Cell) Run() {
+func (c *Cell) Run(ctx context.Context) error {
 	fmt.Println("Please implement Run() method for cell", reflect.TypeOf(c.ICell).Elem().Name())
-	c.OnCellStart()
+	c.OnCellStart(ctx)
 	defer c.OnCellFinished()
+	return errinfo.ErrInvalidMethod
 }
 func (c *Cell) Stop() {
-	c.running = false
+	c.stopped.Store(true)
 }
-func (c *Cell) SetInput(e *Edge) {
-	c.input = e
+func (c *Cell) OutputEdges() []*Edge {
+	edges := make([]*Edge, 0)
+	for _, p := range c.outputs {
+		edges = append(edges, p.edges...)
+	}
+	return edges
 }
-func (c *Cell) SetOutput(e *Edge) {
-	c.output = e
+func (c *Cell) Stats() CellStats {
+	started, finished := c.started.Load(), c.finished.Load()
+	stats := CellStats{
+		InputWait:  time.Duration(c.inputWait.Load()),
+		OutputWait: time.Duration(c.outputWait.Load()),
+	}
+	if started == 0 {
+		return stats
+	}
+	if finished == 0 {
+		finished = time.Now().UnixNano()
+	}
+	stats.Elapsed = time.Duration(finished - started)
+	return stats
+}
+func (c *Cell) SetInput(port string, e *Edge) error {
+	p, err := findPort(c.inputs, port)
+	if err != nil {
+		return err
+	}
+	if len(p.edges) > 0 {
+		return fmt.Errorf("%w: input %v already connected", errinfo.ErrInvalidPort, p.name)
+	}
+	p.edges = append(p.edges, e)
+	return nil
+}
+func (c *Cell) SetOutput(port string, e *Edge) error {
+	p, err := findPort(c.outputs, port)
+	if err != nil {
+		return err
+	}
+	p.edges = append(p.edges, e)
+	return nil
 }
 
 // General method for custom cells to control the flow
-func (c *Cell) Init(stopChan chan bool, config Config) {
-	c.stopChan = stopChan
-	if v, ok := config[config_cel_id]; ok {
+func (c *Cell) Init(config Config) {
+	if v, ok := config[CONFIG_CELL_ID]; ok {
 		c.id = v
 	} else {
 		c.id = uuid.New().String()
 	}
-	c.running = false
+	c.ctx = context.Background()
+}
+
+// AddInputPort declares an input port accepting the formats, the first one is the default port
+func (c *Cell) AddInputPort(name string, formats ...Format) {
+	c.inputs = append(c.inputs, &port{name: name, formats: formats})
 }
-func (c *Cell) OnCellStart() {
-	c.running = true
+
+// AddOutputPort declares an output port producing the formats, the first one is the default port
+func (c *Cell) AddOutputPort(name string, formats ...Format) {
+	c.outputs = append(c.outputs, &port{name: name, formats: formats})
+}
+
+// InitPorts declares the ports of the spec, so the ports negotiated are the ones shown by the help
+func (c *Cell) InitPorts(spec *Spec) {
+	for _, p := range spec.Inputs {
+		c.AddInputPort(p.Name, p.Formats...)
+	}
+	for _, p := range spec.Outputs {
+		c.AddOutputPort(p.Name, p.Formats...)
+	}
+}
+
+// RestrictOutputPort narrows an output port declared by InitPorts to the formats selected by the config,
+// return error if a format is not declared by the spec
+func (c *Cell) RestrictOutputPort(name string, formats ...Format) error {
+	p, err := findPort(c.outputs, name)
+	if err != nil {
+		return err
+	}
+	for _, f := range formats {
+		if !slices.Contains(p.formats, f) {
+			return fmt.Errorf("%w: %v does not produce %v", errinfo.ErrInvalidPort, name, f)
+		}
+	}
+	p.formats = formats
+	return nil
+}
+func (c *Cell) OnCellStart(ctx context.Context) {
+	c.ctx = ctx
+	c.started.Store(time.Now().UnixNano())
 }
 func (c *Cell) OnCellFinished() {
-	c.stopChan <- true
-	if c.output != nil {
-		c.output.Close()
+	c.finished.Store(time.Now().UnixNano())
+	for _, p := range c.outputs {
+		for _, e := range p.edges {
+			e.Close()
+		}
+	}
+	// the cell may finish before its inputs are closed (e.g. on error),
+	// keep consuming so the upstream cells are never blocked
+	for _, p := range c.inputs {
+		for _, e := range p.edges {
+			go e.drain()
+		}
 	}
 }
+
+// Running reports whether a source cell should keep producing
 func (c *Cell) Running() bool {
-	return c.running
+	return !c.stopped.Load() && c.ctx.Err() == nil
+}
+
+// Context is canceled when the cell is aborted
+func (c *Cell) Context() context.Context {
+	return c.ctx
 }
+
+// GetInput reads from the default input port
 func (c *Cell) GetInput() (CellUnit, bool) {
-	if c.input != nil {
-		v, ok := <-c.input.Channel()
+	return c.GetPortInput(DEFAULT_PORT)
+}
+
+// GetPortInput blocks until a unit is available
+// return false if the input is closed or the cell is aborted
+func (c *Cell) GetPortInput(port string) (CellUnit, bool) {
+	if p, err := findPort(c.inputs, port); err == nil && len(p.edges) > 0 {
+		v, wait, ok := p.edges[0].receive(c.ctx.Done())
+		c.inputWait.Add(int64(wait))
 		return v, ok
 	}
 	return nil, false
 }
-func (c *Cell) PutOutput(unit CellUnit) {
-	if c.output != nil {
-		c.output.Channel() <- unit
+
+// PutOutput delivers the unit to every edge of the default output port,
+// downstream cells should treat the unit data as read-only
+func (c *Cell) PutOutput(unit CellUnit) bool {
+	return c.PutPortOutput(DEFAULT_PORT, unit)
+}
+
+// PutPortOutput blocks until every edge of the port accepts the unit
+// return false if the cell is aborted
+func (c *Cell) PutPortOutput(port string, unit CellUnit) bool {
+	p, err := findPort(c.outputs, port)
+	if err != nil || len(p.edges) == 0 {
+		Release(unit)
+		return true
+	}
+	// every edge releases the unit once
+	retain(unit, len(p.edges)-1)
+	for i, e := range p.edges {
+		wait, ok := e.send(c.ctx.Done(), unit)
+		c.outputWait.Add(int64(wait))
+		if !ok {
+			for range p.edges[i:] {
+				Release(unit)
+			}
+			return false
+		}
 	}
+	return true
 }
@@ -0,0 +1,68 @@
+package icell
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Checker is implemented by cells able to verify their resources before running,
+// e.g. the input file is readable, without processing any data
+type Checker interface {
+	Check() error
+}
+
+// CheckReadable verifies that the file can be opened for reading
+func CheckReadable(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%v is a directory", filename)
+	}
+	return nil
+}
+
+// CheckWritableDir verifies that files can be created in the directory,
+// or in its closest existing parent if the directory will be created
+func CheckWritableDir(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%v is not a directory", dir)
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if !errors.Is(err, fs.ErrNotExist) || parent == dir {
+			return err
+		}
+		dir = parent
+	}
+	file, err := os.CreateTemp(dir, ".tsanalyzer-check-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+// CheckWritable verifies that the file can be created
+func CheckWritable(filename string) error {
+	if info, err := os.Stat(filename); err == nil && info.IsDir() {
+		return fmt.Errorf("%v is a directory", filename)
+	}
+	return CheckWritableDir(filepath.Dir(filename))
+}
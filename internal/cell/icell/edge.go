@@ -1,9 +1,13 @@
 package icell
 
 import (
+	"fmt"
 	"reflect"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
 )
 
 const (
@@ -13,22 +17,47 @@ const (
 type Edge struct {
 	id string
 
-	src ICell
-	dst ICell
+	src     ICell
+	srcPort string
+	dst     ICell
+	dstPort string
 
+	formats  []Format // negotiated formats, empty if both sides accept any format
+	opts     EdgeOptions
 	unitType reflect.Type
 	channel  chan CellUnit
 	open     bool
+
+	units    atomic.Uint64
+	bytes    atomic.Uint64
+	dropped  atomic.Uint64
+	maxDepth atomic.Int64
+	blocked  atomic.Int64 // nanoseconds
 }
 
-func newEdge(src, dst ICell) (*Edge, error) {
+func newEdge(src ICell, srcPort string, dst ICell, dstPort string, srcFormats, dstFormats []Format, opts EdgeOptions) (*Edge, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	formats := IntersectFormats(srcFormats, dstFormats)
+	if len(formats) == 0 && (len(srcFormats) > 0 || len(dstFormats) > 0) {
+		return nil, fmt.Errorf("%w: output formats %v not accepted by input formats %v",
+			errinfo.ErrFormatMismatch, srcFormats, dstFormats)
+	}
 	e := &Edge{
 		id:      uuid.NewString(),
 		src:     src,
+		srcPort: srcPort,
 		dst:     dst,
-		channel: make(chan CellUnit, EDGE_BUFFER),
+		dstPort: dstPort,
+		formats: formats,
+		opts:    EdgeOptions{Capacity: opts.capacity(), Policy: opts.policy()},
+		channel: make(chan CellUnit, opts.capacity()),
 		open:    true,
 	}
+	if len(formats) == 1 {
+		e.unitType = FormatToType[string(formats[0])]
+	}
 	return e, nil
 }
 
@@ -40,10 +69,28 @@ func (e *Edge) Src() ICell {
 	return e.src
 }
 
+func (e *Edge) SrcPort() string {
+	return e.srcPort
+}
+
 func (e *Edge) Dst() ICell {
 	return e.dst
 }
 
+func (e *Edge) DstPort() string {
+	return e.dstPort
+}
+
+func (e *Edge) Formats() []Format {
+	return e.formats
+}
+
+// Options returns the capacity and the policy of the queue
+func (e *Edge) Options() EdgeOptions {
+	return e.opts
+}
+
+// UnitType is the type of the unit data if the format is fully negotiated
 func (e *Edge) UnitType() reflect.Type {
 	return e.unitType
 }
@@ -54,6 +101,94 @@ func (e *Edge) Channel() chan CellUnit {
 
 func (e *Edge) Close() {
 	if e.open {
+		e.open = false
 		close(e.channel)
 	}
 }
+
+// Stats returns a snapshot of the edge metrics, safe to call while running
+func (e *Edge) Stats() EdgeStats {
+	return EdgeStats{
+		Units:    e.units.Load(),
+		Dropped:  e.dropped.Load(),
+		Bytes:    e.bytes.Load(),
+		Depth:    len(e.channel),
+		MaxDepth: int(e.maxDepth.Load()),
+		Capacity: cap(e.channel),
+		Policy:   e.opts.Policy,
+		Blocked:  time.Duration(e.blocked.Load()),
+	}
+}
+
+// send puts the unit to the queue according to the policy of the edge
+// return the time blocked on a full queue, and false if done is closed first
+func (e *Edge) send(done <-chan struct{}, unit CellUnit) (time.Duration, bool) {
+	var blocked time.Duration
+	select {
+	case e.channel <- unit:
+	default:
+		switch e.opts.Policy {
+		case POLICY_DROP_NEWEST:
+			e.dropped.Add(1)
+			Release(unit)
+			return 0, true
+		case POLICY_DROP_OLDEST:
+			e.dropOldest(unit)
+		default:
+			start := time.Now()
+			select {
+			case e.channel <- unit:
+			case <-done:
+				return time.Since(start), false
+			}
+			blocked = time.Since(start)
+			e.blocked.Add(int64(blocked))
+		}
+	}
+	e.units.Add(1)
+	e.bytes.Add(uint64(unitSize(unit)))
+	setMax(&e.maxDepth, int64(len(e.channel)))
+	return blocked, true
+}
+
+// dropOldest discards queued units until the unit is accepted,
+// the downstream cell may take units concurrently
+func (e *Edge) dropOldest(unit CellUnit) {
+	for {
+		select {
+		case old := <-e.channel:
+			e.dropped.Add(1)
+			Release(old)
+		default:
+		}
+		select {
+		case e.channel <- unit:
+			return
+		default:
+		}
+	}
+}
+
+// receive blocks until a unit is available
+// return the time blocked on an empty queue, and false if the edge is closed or done is closed
+func (e *Edge) receive(done <-chan struct{}) (CellUnit, time.Duration, bool) {
+	select {
+	case v, ok := <-e.channel:
+		return v, 0, ok
+	default:
+	}
+	start := time.Now()
+	select {
+	case v, ok := <-e.channel:
+		return v, time.Since(start), ok
+	case <-done:
+		return nil, time.Since(start), false
+	}
+}
+
+// drain discards the units until the edge is closed
+func (e *Edge) drain() {
+	for unit := range e.channel {
+		Release(unit)
+	}
+}
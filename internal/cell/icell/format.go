@@ -2,8 +2,10 @@ package icell
 
 import (
 	"reflect"
+	"slices"
 
 	"github.com/Comcast/gots/v2/packet"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
 )
 
 type Format string
@@ -13,6 +15,9 @@ const (
 	BYTE_SLICE = "[]byte"
 	STRING     = "string"
 	TS_PACKET  = "ts_packet"
+	TS_PACKETS = "ts_packets" // consecutive ts packets, the metadata describes the first one
+	PES_PACKET = "pes_packet" // the metadata describes the first ts packet of the pes packet
+	SECTION    = "section"    // the metadata describes the first ts packet of the section
 )
 
 var (
@@ -20,5 +25,26 @@ var (
 		BYTE_SLICE: reflect.TypeFor[[]byte](),
 		STRING:     reflect.TypeFor[string](),
 		TS_PACKET:  reflect.TypeFor[packet.Packet](),
+		TS_PACKETS: reflect.TypeFor[[]packet.Packet](),
+		PES_PACKET: reflect.TypeFor[*ts.PesPacket](),
+		SECTION:    reflect.TypeFor[*ts.Section](),
 	}
 )
+
+// IntersectFormats returns the formats of src supported by dst in the order of src,
+// an empty list of formats accepts any format
+func IntersectFormats(src, dst []Format) []Format {
+	if len(dst) == 0 {
+		return src
+	}
+	if len(src) == 0 {
+		return dst
+	}
+	result := make([]Format, 0)
+	for _, f := range src {
+		if slices.Contains(dst, f) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
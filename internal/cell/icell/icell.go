@@ -1,16 +1,28 @@
 package icell
 
+import "context"
+
 // This is the interface for accessing Cells in pipeline
 type ICell interface {
 	// pipeline base methods
 	Id() string
-	Connect(ICell) error
-	SetInput(e *Edge)
-	SetOutput(e *Edge)
+	InputPorts() []string
+	OutputPorts() []string
+	InputFormats(port string) ([]Format, error)
+	OutputFormats(port string) ([]Format, error)
+	Connect(port string, next ICell, nextPort string, opts EdgeOptions) error // use DEFAULT_PORT for the first declared port
+	SetInput(port string, e *Edge) error
+	SetOutput(port string, e *Edge) error // can be called multiple times to fan out
+	OutputEdges() []*Edge
+
+	// metrics, safe to call while running
+	Stats() CellStats
 
 	// non go routine methods
-	Stop() // force stop the cell
+	Stop() // stop producing, the cell finishes when its inputs are drained
 
 	// go routine methods
-	Run() // go Run() to start the cell processing, should terminate automatically
+	// go Run(ctx) to start the cell processing, should terminate automatically,
+	// or as soon as possible when ctx is canceled
+	Run(ctx context.Context) error
 }
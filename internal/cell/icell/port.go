@@ -0,0 +1,47 @@
+package icell
+
+import (
+	"fmt"
+
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+const (
+	// DEFAULT_PORT refers to the first declared port of a cell
+	DEFAULT_PORT = ""
+
+	// conventional names for cells with a single input or output
+	INPUT_PORT  = "in"
+	OUTPUT_PORT = "out"
+)
+
+// port is a named connection point of a cell
+// an input port accepts a single edge, an output port can fan out to multiple edges
+type port struct {
+	name    string
+	formats []Format
+	edges   []*Edge
+}
+
+func findPort(ports []*port, name string) (*port, error) {
+	if len(ports) < 1 {
+		return nil, fmt.Errorf("%w: no port declared", errinfo.ErrInvalidPort)
+	}
+	if name == DEFAULT_PORT {
+		return ports[0], nil
+	}
+	for _, p := range ports {
+		if p.name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", errinfo.ErrInvalidPort, name)
+}
+
+func portNames(ports []*port) []string {
+	names := make([]string, len(ports))
+	for i, p := range ports {
+		names[i] = p.name
+	}
+	return names
+}
@@ -0,0 +1,31 @@
+package icell
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+func TestInitPorts(t *testing.T) {
+	spec := Spec{
+		Inputs:  []PortSpec{{Name: INPUT_PORT, Formats: []Format{BYTE_SLICE}}},
+		Outputs: []PortSpec{{Name: OUTPUT_PORT, Formats: []Format{TS_PACKET, TS_PACKETS}}},
+	}
+	c := &Cell{}
+	c.InitPorts(&spec)
+	if formats, err := c.OutputFormats(OUTPUT_PORT); err != nil || !slices.Equal(formats, spec.Outputs[0].Formats) {
+		t.Errorf("output formats not match the spec: %v %v\n", formats, err)
+	}
+
+	if err := c.RestrictOutputPort(OUTPUT_PORT, TS_PACKETS); err != nil {
+		t.Fatal(err)
+	}
+	if formats, _ := c.OutputFormats(OUTPUT_PORT); !slices.Equal(formats, []Format{TS_PACKETS}) {
+		t.Errorf("expected the output restricted to ts_packets, but get %v\n", formats)
+	}
+	if err := c.RestrictOutputPort(OUTPUT_PORT, STRING); !errors.Is(err, errinfo.ErrInvalidPort) {
+		t.Errorf("expected invalid port for a format not in the spec, but get %v\n", err)
+	}
+}
@@ -0,0 +1,188 @@
+package icell
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+type PropertyType int
+
+const (
+	PROP_STRING PropertyType = iota
+	PROP_INT
+	PROP_UINT
+	PROP_BOOL
+	PROP_INT_LIST // integers split by ","
+)
+
+func (t PropertyType) String() string {
+	switch t {
+	case PROP_INT:
+		return "int"
+	case PROP_UINT:
+		return "uint"
+	case PROP_BOOL:
+		return "bool"
+	case PROP_INT_LIST:
+		return "int list"
+	default:
+		return "string"
+	}
+}
+
+// Property describes a config key accepted by a cell
+type Property struct {
+	Name        string
+	Type        PropertyType
+	Default     string // applied if not provided, empty for no default
+	Required    bool
+	Enum        []string // allowed values, empty for any
+	Description string
+}
+
+// Usage is the one line help of the property
+func (p *Property) Usage() string {
+	attrs := []string{p.Type.String()}
+	if p.Required {
+		attrs = append(attrs, "required")
+	}
+	if p.Default != "" {
+		attrs = append(attrs, "default "+p.Default)
+	}
+	if len(p.Enum) > 0 {
+		attrs = append(attrs, "one of "+strings.Join(p.Enum, "|"))
+	}
+	return fmt.Sprintf("%v: %v (%v)", p.Name, p.Description, strings.Join(attrs, ", "))
+}
+
+func (p *Property) parse(value string) (interface{}, error) {
+	if len(p.Enum) > 0 && !slices.Contains(p.Enum, value) {
+		return nil, fmt.Errorf("%v=%v not in %v", p.Name, value, p.Enum)
+	}
+	switch p.Type {
+	case PROP_INT:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v=%v is not an int", p.Name, value)
+		}
+		return v, nil
+	case PROP_UINT:
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%v=%v is not an uint", p.Name, value)
+		}
+		return v, nil
+	case PROP_BOOL:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%v=%v is not a bool", p.Name, value)
+		}
+		return v, nil
+	case PROP_INT_LIST:
+		list := make([]int, 0)
+		for _, s := range strings.Split(value, ",") {
+			v, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("%v=%v is not an int list", p.Name, value)
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	default:
+		return value, nil
+	}
+}
+
+// Schema is the list of properties accepted by a cell
+type Schema []Property
+
+// Find the property by name
+func (s Schema) Find(name string) (*Property, bool) {
+	for i := range s {
+		if s[i].Name == name {
+			return &s[i], true
+		}
+	}
+	return nil, false
+}
+
+/* ParseConfig validates the config of the cell against the schema
+ * defaults are applied to missing properties, unknown properties are warned
+ * return every problem found wrapped with errinfo.ErrInvalidCellConfig
+ */
+func ParseConfig(name string, s Schema, config Config) (Properties, error) {
+	props := make(Properties)
+	errs := make([]error, 0)
+	for i := range s {
+		p := &s[i]
+		value, ok := config[p.Name]
+		if !ok {
+			if p.Required {
+				errs = append(errs, fmt.Errorf("%v not provided", p.Name))
+				continue
+			}
+			if p.Default == "" {
+				continue
+			}
+			value = p.Default
+		}
+		v, err := p.parse(value)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		props[p.Name] = v
+	}
+	for _, key := range UnknownProperties(s, config) {
+		fmt.Printf("[%v] warning: unknown property %v\n", name, key)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w for %v: %w", errinfo.ErrInvalidCellConfig, name, errors.Join(errs...))
+	}
+	return props, nil
+}
+
+// UnknownProperties returns the sorted keys of the config not declared in the schema
+func UnknownProperties(s Schema, config Config) []string {
+	keys := make([]string, 0)
+	for key := range config {
+		if _, ok := s.Find(key); !ok && key != CONFIG_CELL_ID {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Properties are the typed values parsed by ParseConfig
+type Properties map[string]interface{}
+
+func (p Properties) Has(name string) bool {
+	_, ok := p[name]
+	return ok
+}
+func (p Properties) String(name string) string {
+	v, _ := p[name].(string)
+	return v
+}
+func (p Properties) Int(name string) int {
+	v, _ := p[name].(int)
+	return v
+}
+func (p Properties) Uint(name string) uint64 {
+	v, _ := p[name].(uint64)
+	return v
+}
+func (p Properties) Bool(name string) bool {
+	v, _ := p[name].(bool)
+	return v
+}
+func (p Properties) IntList(name string) []int {
+	v, _ := p[name].([]int)
+	return v
+}
@@ -0,0 +1,67 @@
+package icell_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+var testSchema = icell.Schema{
+	{Name: "name", Type: icell.PROP_STRING, Required: true},
+	{Name: "size", Type: icell.PROP_UINT, Default: "10"},
+	{Name: "pids", Type: icell.PROP_INT_LIST},
+	{Name: "plot", Type: icell.PROP_BOOL, Default: "false"},
+	{Name: "mode", Type: icell.PROP_STRING, Enum: []string{"a", "b"}},
+}
+
+func TestParseConfig(t *testing.T) {
+	props, err := icell.ParseConfig("test", testSchema, icell.Config{
+		"id":   "cell",
+		"name": "in.ts",
+		"pids": "32,33",
+		"plot": "true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if props.String("name") != "in.ts" {
+		t.Errorf("name not matched, expected in.ts, but get %v\n", props.String("name"))
+	}
+	if props.Uint("size") != 10 {
+		t.Errorf("default not applied, expected 10, but get %v\n", props.Uint("size"))
+	}
+	if !slices.Equal(props.IntList("pids"), []int{32, 33}) {
+		t.Errorf("pids not matched, expected [32 33], but get %v\n", props.IntList("pids"))
+	}
+	if !props.Bool("plot") {
+		t.Error("plot not matched, expected true")
+	}
+	if props.Has("mode") {
+		t.Error("mode should not be set without default")
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	invalids := []icell.Config{
+		{},
+		{"name": "in.ts", "size": "-1"},
+		{"name": "in.ts", "pids": "32,x"},
+		{"name": "in.ts", "plot": "yes"},
+		{"name": "in.ts", "mode": "c"},
+	}
+	for _, config := range invalids {
+		if _, err := icell.ParseConfig("test", testSchema, config); !errors.Is(err, errinfo.ErrInvalidCellConfig) {
+			t.Errorf("expected invalid config for %v, but get %v\n", config, err)
+		}
+	}
+}
+
+func TestUnknownProperties(t *testing.T) {
+	unknown := icell.UnknownProperties(testSchema, icell.Config{"id": "a", "name": "x", "nmae": "y", "colour": "z"})
+	if !slices.Equal(unknown, []string{"colour", "nmae"}) {
+		t.Errorf("unknown properties not matched, expected [colour nmae], but get %v\n", unknown)
+	}
+}
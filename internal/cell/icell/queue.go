@@ -0,0 +1,100 @@
+package icell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+// EdgePolicy decides what happens when the queue of an edge is full
+type EdgePolicy string
+
+const (
+	POLICY_BLOCK       EdgePolicy = "block"       // the upstream cell waits, no data is lost
+	POLICY_DROP_NEWEST EdgePolicy = "drop-newest" // the unit being put is dropped
+	POLICY_DROP_OLDEST EdgePolicy = "drop-oldest" // the oldest queued unit is dropped
+)
+
+const (
+	CONFIG_EDGE_CAPACITY = "capacity"
+	CONFIG_EDGE_POLICY   = "policy"
+)
+
+// EdgeOptions configures the queue of a connection,
+// the zero value blocks with a queue of EDGE_BUFFER units
+type EdgeOptions struct {
+	Capacity int
+	Policy   EdgePolicy
+}
+
+// EdgeSchema describes the properties of a queue in the pipe syntax and pipeline files
+var EdgeSchema = Schema{
+	{
+		Name:        CONFIG_EDGE_CAPACITY,
+		Type:        PROP_UINT,
+		Default:     strconv.Itoa(EDGE_BUFFER),
+		Description: "number of units queued between the cells",
+	},
+	{
+		Name:        CONFIG_EDGE_POLICY,
+		Type:        PROP_STRING,
+		Default:     string(POLICY_BLOCK),
+		Enum:        []string{string(POLICY_BLOCK), string(POLICY_DROP_NEWEST), string(POLICY_DROP_OLDEST)},
+		Description: "behavior when the queue is full",
+	},
+}
+
+// ParseEdgeOptions parses the queue properties described by EdgeSchema
+func ParseEdgeOptions(config Config) (EdgeOptions, error) {
+	props, err := ParseConfig("queue", EdgeSchema, config)
+	if err != nil {
+		return EdgeOptions{}, fmt.Errorf("%w: %w", errinfo.ErrInvalidEdgeOptions, err)
+	}
+	opts := EdgeOptions{
+		Capacity: int(props.Uint(CONFIG_EDGE_CAPACITY)),
+		Policy:   EdgePolicy(props.String(CONFIG_EDGE_POLICY)),
+	}
+	if opts.Capacity == 0 {
+		return EdgeOptions{}, fmt.Errorf("%w: capacity must be positive", errinfo.ErrInvalidEdgeOptions)
+	}
+	return opts, nil
+}
+
+func (o EdgeOptions) validate() error {
+	if o.Capacity < 0 {
+		return fmt.Errorf("%w: negative capacity %v", errinfo.ErrInvalidEdgeOptions, o.Capacity)
+	}
+	switch o.Policy {
+	case "", POLICY_BLOCK, POLICY_DROP_NEWEST, POLICY_DROP_OLDEST:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown policy %v", errinfo.ErrInvalidEdgeOptions, o.Policy)
+}
+
+func (o EdgeOptions) capacity() int {
+	if o.Capacity == 0 {
+		return EDGE_BUFFER
+	}
+	return o.Capacity
+}
+
+func (o EdgeOptions) policy() EdgePolicy {
+	if o.Policy == "" {
+		return POLICY_BLOCK
+	}
+	return o.Policy
+}
+
+// String shows the options different from the default
+func (o EdgeOptions) String() string {
+	opts := make([]string, 0, 2)
+	if o.capacity() != EDGE_BUFFER {
+		opts = append(opts, fmt.Sprintf("%v=%v", CONFIG_EDGE_CAPACITY, o.capacity()))
+	}
+	if o.policy() != POLICY_BLOCK {
+		opts = append(opts, fmt.Sprintf("%v=%v", CONFIG_EDGE_POLICY, o.policy()))
+	}
+	return strings.Join(opts, " ")
+}
@@ -0,0 +1,51 @@
+package icell
+
+import (
+	"testing"
+)
+
+func sendUnits(t *testing.T, policy EdgePolicy, n int) *Edge {
+	e, err := newEdge(nil, DEFAULT_PORT, nil, DEFAULT_PORT, nil, nil, EdgeOptions{Capacity: 2, Policy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	for i := 0; i < n; i++ {
+		if _, ok := e.send(done, NewCellUnit(i, STRING)); !ok {
+			t.Fatalf("failed to send unit %v\n", i)
+		}
+	}
+	return e
+}
+
+func TestEdgeDropNewest(t *testing.T) {
+	e := sendUnits(t, POLICY_DROP_NEWEST, 5)
+	if stats := e.Stats(); stats.Dropped != 3 || stats.Units != 2 {
+		t.Errorf("expected 2 units and 3 dropped, but get %v units and %v dropped\n", stats.Units, stats.Dropped)
+	}
+	if v := (<-e.Channel()).Data(); v != 0 {
+		t.Errorf("expected the oldest unit 0 kept, but get %v\n", v)
+	}
+}
+
+func TestEdgeDropOldest(t *testing.T) {
+	e := sendUnits(t, POLICY_DROP_OLDEST, 5)
+	if stats := e.Stats(); stats.Dropped != 3 || stats.Units != 5 {
+		t.Errorf("expected 5 units and 3 dropped, but get %v units and %v dropped\n", stats.Units, stats.Dropped)
+	}
+	if v := (<-e.Channel()).Data(); v != 3 {
+		t.Errorf("expected the newest units kept from 3, but get %v\n", v)
+	}
+}
+
+func TestEdgeOptionsInvalid(t *testing.T) {
+	invalids := []EdgeOptions{
+		{Capacity: -1},
+		{Policy: "leak"},
+	}
+	for _, opts := range invalids {
+		if _, err := newEdge(nil, DEFAULT_PORT, nil, DEFAULT_PORT, nil, nil, opts); err == nil {
+			t.Errorf("expected failure for %v\n", opts)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package icell
+
+// Spec describes a cell for help, validation and completion
+type Spec struct {
+	Description string
+	Inputs      []PortSpec
+	Outputs     []PortSpec
+	Schema      Schema
+}
+
+// PortSpec describes a port and the formats it supports
+type PortSpec struct {
+	Name    string
+	Formats []Format
+}
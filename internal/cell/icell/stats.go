@@ -0,0 +1,59 @@
+package icell
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Comcast/gots/v2/packet"
+)
+
+// EdgeStats is a snapshot of the units passed through an edge
+type EdgeStats struct {
+	Units    uint64        // units accepted by the queue
+	Bytes    uint64        // bytes accepted by the queue
+	Dropped  uint64        // units dropped by the policy
+	Depth    int           // units queued at the time of the snapshot
+	MaxDepth int           // high-water mark of the queue
+	Capacity int           // size of the queue
+	Policy   EdgePolicy    // behavior when the queue is full
+	Blocked  time.Duration // time the upstream cell blocked on a full queue
+}
+
+// CellStats is a snapshot of where a cell spent its time
+type CellStats struct {
+	Elapsed    time.Duration // since the cell started, until it finished
+	InputWait  time.Duration // blocked on empty inputs
+	OutputWait time.Duration // blocked on full outputs
+}
+
+// Busy is the time spent processing, excluding the time waiting for other cells
+func (s CellStats) Busy() time.Duration {
+	return max(s.Elapsed-s.InputWait-s.OutputWait, 0)
+}
+
+// unitSize returns the size of the unit data in bytes, 0 if unknown
+func unitSize(unit CellUnit) int {
+	switch data := unit.Data().(type) {
+	case []byte:
+		return len(data)
+	case string:
+		return len(data)
+	case *packet.Packet:
+		return len(data)
+	case packet.Packet:
+		return len(data)
+	case []packet.Packet:
+		return len(data) * packet.PacketSize
+	}
+	return 0
+}
+
+// setMax raises v to n if n is larger
+func setMax(v *atomic.Int64, n int64) {
+	for {
+		old := v.Load()
+		if n <= old || v.CompareAndSwap(old, n) {
+			return
+		}
+	}
+}
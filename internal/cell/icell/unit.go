@@ -1,13 +1,132 @@
 package icell
 
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Comcast/gots/v2/packet"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
+)
+
 type CellUnit interface {
 	Data() interface{}
 	Format() Format
+	Metadata() *Metadata // nil if the producer did not provide any
+}
+
+// UNKNOWN marks an unknown offset or index in the metadata
+const UNKNOWN int64 = -1
+
+// Metadata describes where and when the data of a unit was received,
+// converters copy it to the units they produce
+type Metadata struct {
+	Source    string        // id of the cell which received the data
+	Received  time.Time     // wall-clock time when the data was received
+	Monotonic time.Duration // monotonic time when the data was received on the clock of the source, see MonotonicNow
+	Offset    int64         // byte offset of the data in the source, UNKNOWN if not known
+	Index     int64         // index of the packet in the source counting from 0, UNKNOWN if not a packet
+
+	// packets of 192 or 204 bytes in the source, stripped to ts packets
+	PacketSize int    // size of the packets in the source, 0 for 188 bytes
+	Extra      []byte // the stripped bytes of every packet, see ExtraAt
+
+	Origin any // data specific to the source cell, e.g. the sender of a datagram, nil if none
+}
+
+var monotonicEpoch = time.Now()
+
+// MonotonicNow returns the monotonic time since the process started,
+// unlike the wall-clock time it never jumps
+func MonotonicNow() time.Duration {
+	return time.Since(monotonicEpoch)
+}
+
+// NewMetadata returns the metadata of data received now by the source cell
+func NewMetadata(source string) *Metadata {
+	now := time.Now()
+	return &Metadata{
+		Source:    source,
+		Received:  now,
+		Monotonic: now.Sub(monotonicEpoch),
+		Offset:    UNKNOWN,
+		Index:     UNKNOWN,
+	}
+}
+
+// PacketAt returns the metadata of the nth packet of a TS_PACKETS unit described by m
+func (m *Metadata) PacketAt(n int) *Metadata {
+	if m == nil {
+		return nil
+	}
+	nth := *m
+	if nth.Index != UNKNOWN {
+		nth.Index += int64(n)
+	}
+	if nth.Offset != UNKNOWN {
+		nth.Offset += int64(n * m.packetSize())
+	}
+	nth.Extra = m.ExtraAt(n)
+	return &nth
+}
+
+func (m *Metadata) packetSize() int {
+	if m.PacketSize == 0 {
+		return packet.PacketSize
+	}
+	return m.PacketSize
+}
+
+// ExtraAt returns the bytes stripped from the nth packet of the unit,
+// the M2TS header of 192-byte packets or the parity of 204-byte packets
+func (m *Metadata) ExtraAt(n int) []byte {
+	if m == nil {
+		return nil
+	}
+	size := m.packetSize() - packet.PacketSize
+	if size <= 0 || len(m.Extra) < (n+1)*size {
+		return nil
+	}
+	return m.Extra[n*size : (n+1)*size]
+}
+
+// ArrivalTimestamp returns the 30-bit arrival time stamp in 27MHz of the nth packet
+// from its M2TS header, false if the source is not M2TS
+func (m *Metadata) ArrivalTimestamp(n int) (uint32, bool) {
+	if m == nil || m.PacketSize != ts.M2TS_PACKET_SIZE {
+		return 0, false
+	}
+	header := m.ExtraAt(n)
+	if header == nil {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(header) & ts.M2TS_TIMESTAMP_MASK, true
+}
+
+// Location describes where the unit data is in the source, for error reports
+func (m *Metadata) Location() string {
+	if m == nil {
+		return "unknown location"
+	}
+	location := make([]string, 0, 3)
+	if m.Source != "" {
+		location = append(location, m.Source)
+	}
+	if m.Index != UNKNOWN {
+		location = append(location, fmt.Sprintf("packet %v", m.Index))
+	}
+	if m.Offset != UNKNOWN {
+		location = append(location, fmt.Sprintf("offset %v", m.Offset))
+	}
+	return strings.Join(location, " ")
 }
 
 type cellUnit struct {
-	data   interface{}
-	format Format
+	data     interface{}
+	format   Format
+	metadata *Metadata
 }
 
 func NewCellUnit(data interface{}, format Format) *cellUnit {
@@ -17,6 +136,16 @@ func NewCellUnit(data interface{}, format Format) *cellUnit {
 	}
 }
 
+// NewCellUnitWithMetadata returns a unit carrying the metadata,
+// the metadata should not be modified afterwards as it may be shared by downstream cells
+func NewCellUnitWithMetadata(data interface{}, format Format, metadata *Metadata) *cellUnit {
+	return &cellUnit{
+		data:     data,
+		format:   format,
+		metadata: metadata,
+	}
+}
+
 func (u *cellUnit) Data() interface{} {
 	return u.data
 }
@@ -24,3 +153,45 @@ func (u *cellUnit) Data() interface{} {
 func (u *cellUnit) Format() Format {
 	return u.format
 }
+
+func (u *cellUnit) Metadata() *Metadata {
+	return u.metadata
+}
+
+// pooledUnit returns its buffer to the pool once every consumer released it
+type pooledUnit struct {
+	cellUnit
+	refs atomic.Int32
+	free func()
+}
+
+// NewPooledCellUnit returns a unit whose data is backed by a reusable buffer,
+// free is called once every cell receiving the unit called Release
+func NewPooledCellUnit(data interface{}, format Format, metadata *Metadata, free func()) *pooledUnit {
+	u := &pooledUnit{
+		cellUnit: cellUnit{
+			data:     data,
+			format:   format,
+			metadata: metadata,
+		},
+		free: free,
+	}
+	u.refs.Store(1)
+	return u
+}
+
+// Release tells that the cell no longer uses the unit data,
+// call it only for units consumed and not forwarded to other cells.
+// Units not released are garbage collected as usual
+func Release(unit CellUnit) {
+	if u, ok := unit.(*pooledUnit); ok && u.refs.Add(-1) == 0 {
+		u.free()
+	}
+}
+
+// retain adds n consumers to a pooled unit, e.g. when it is sent to several edges
+func retain(unit CellUnit, n int) {
+	if u, ok := unit.(*pooledUnit); ok {
+		u.refs.Add(int32(n))
+	}
+}
@@ -0,0 +1,46 @@
+package icell_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
+)
+
+func TestMetadataM2TS(t *testing.T) {
+	m := &icell.Metadata{
+		Offset:     0,
+		Index:      10,
+		PacketSize: ts.M2TS_PACKET_SIZE,
+		Extra:      []byte{0xC0, 0x00, 0x00, 0x01, 0x00, 0x00, 0x01, 0x00},
+	}
+	nth := m.PacketAt(1)
+	if nth.Offset != ts.M2TS_PACKET_SIZE || nth.Index != 11 {
+		t.Errorf("offset %v index %v", nth.Offset, nth.Index)
+	}
+	if !bytes.Equal(nth.Extra, []byte{0x00, 0x00, 0x01, 0x00}) {
+		t.Errorf("extra %x", nth.Extra)
+	}
+	// the copy permission bits are not part of the time stamp
+	if ats, ok := m.ArrivalTimestamp(0); !ok || ats != 1 {
+		t.Errorf("arrival time stamp %v %v", ats, ok)
+	}
+	if ats, ok := nth.ArrivalTimestamp(0); !ok || ats != 256 {
+		t.Errorf("arrival time stamp %v %v", ats, ok)
+	}
+	if _, ok := m.ArrivalTimestamp(2); ok {
+		t.Errorf("arrival time stamp out of the unit")
+	}
+}
+
+func TestMetadataTS(t *testing.T) {
+	m := icell.NewMetadata("src")
+	m.Offset = 0
+	if nth := m.PacketAt(2); nth.Offset != 2*ts.TS_PACKET_SIZE || nth.Extra != nil {
+		t.Errorf("offset %v extra %x", nth.Offset, nth.Extra)
+	}
+	if _, ok := m.ArrivalTimestamp(0); ok {
+		t.Errorf("arrival time stamp without M2TS header")
+	}
+}
@@ -1,26 +1,39 @@
 package converter
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"reflect"
-	"slices"
+	"strconv"
 
 	"github.com/Comcast/gots/v2/packet"
 	"github.com/potterxu/tsanalyzer/internal/cell/icell"
 	"github.com/potterxu/tsanalyzer/internal/errinfo"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
 )
 
 const (
 	BytesConverterName string = "bytes_converter"
 
 	config_bytesconverter_outputformat string = "output_format"
+	config_bytesconverter_packetsize   string = "packet_size"
+
+	bytesconverter_packetsize_auto string = "auto"
 )
 
 var (
 	bytesConverterInputFormats  = []icell.Format{icell.BYTE_SLICE}
-	bytesConverterOutputFormats = []icell.Format{icell.TS_PACKET}
+	bytesConverterOutputFormats = []icell.Format{icell.TS_PACKET, icell.TS_PACKETS}
+
+	BytesConverterSpec = icell.Spec{
+		Description: "convert byte array to type",
+		Inputs:      []icell.PortSpec{{Name: icell.INPUT_PORT, Formats: bytesConverterInputFormats}},
+		Outputs:     []icell.PortSpec{{Name: icell.OUTPUT_PORT, Formats: bytesConverterOutputFormats}},
+		Schema: icell.Schema{
+			{Name: config_bytesconverter_outputformat, Type: icell.PROP_STRING, Default: icell.TS_PACKET, Enum: []string{icell.TS_PACKET, icell.TS_PACKETS}, Description: "output format, ts_packets batches the packets of every input buffer"},
+			{Name: config_bytesconverter_packetsize, Type: icell.PROP_STRING, Default: bytesconverter_packetsize_auto, Enum: []string{bytesconverter_packetsize_auto, "188", "192", "204"}, Description: "size of the packets in the input, the extra bytes of 192 and 204 are kept in the metadata"},
+		},
+	}
 )
 
 type BytesConverter struct {
@@ -28,54 +41,50 @@ type BytesConverter struct {
 
 	outputFormat icell.Format
 
-	remainedBytes []byte
+	sync     *ts.Synchronizer
+	chunks   []chunkMetadata // metadata of the input buffers not consumed yet
+	received int64           // stream offset of the next input buffer
+	invalid  uint64          // packets dropped for invalid headers
+	index    int64           // index of the next packet read, the dropped invalid packets included
 }
 
-func BytesConverterHelp() {
-	BytesConverterHelpShort()
-	format := `	IO:
-	  ->cell: %v
-	  cell->: %v
-	Properties:
-	  %v: output format %v
-`
-	fmt.Printf(format,
-		bytesConverterInputFormats,
-		bytesConverterOutputFormats,
-		config_bytesconverter_outputformat,
-		bytesConverterInputFormats)
+// chunkMetadata locates an input buffer in the stream
+type chunkMetadata struct {
+	start    int64
+	metadata *icell.Metadata
 }
 
-func BytesConverterHelpShort() {
-	fmt.Printf("%s: convert byte array to type\n", BytesConverterName)
+// BytesConverterConfig returns the config to convert byte array to the format
+func BytesConverterConfig(outputFormat icell.Format) icell.Config {
+	return icell.Config{
+		config_bytesconverter_outputformat: string(outputFormat),
+	}
 }
 
-func NewBytesConverter(stopChan chan bool, config icell.Config) (icell.ICell, error) {
-	c := &BytesConverter{
-		remainedBytes: nil,
-	}
+func NewBytesConverter(config icell.Config) (icell.ICell, error) {
+	c := &BytesConverter{}
 	c.ICell = c
-	c.Init(stopChan, config)
-
-	if of, ok := config[config_bytesconverter_outputformat]; ok {
-		outputFormat := icell.Format(of)
-		if slices.Contains(bytesConverterOutputFormats, outputFormat) {
-			c.outputFormat = outputFormat
-		} else {
-			fmt.Printf("%v=%v not supported for BytesConverter", config_bytesconverter_outputformat, outputFormat)
-			BytesConverterHelp()
-			return nil, errinfo.ErrInvalidCellConfig
-		}
-	} else {
-		fmt.Printf("%v not provided for BytesConverter\n", config_bytesconverter_outputformat)
-		BytesConverterHelp()
-		return nil, errinfo.ErrInvalidCellConfig
+	c.Init(config)
+	c.InitPorts(&BytesConverterSpec)
+
+	props, err := icell.ParseConfig(BytesConverterName, BytesConverterSpec.Schema, config)
+	if err != nil {
+		return nil, err
+	}
+	c.outputFormat = icell.Format(props.String(config_bytesconverter_outputformat))
+	size := 0 // detected by the synchronizer
+	if value := props.String(config_bytesconverter_packetsize); value != bytesconverter_packetsize_auto {
+		size, _ = strconv.Atoi(value)
+	}
+	c.sync = ts.NewSynchronizer(size)
+	if err := c.RestrictOutputPort(icell.OUTPUT_PORT, c.outputFormat); err != nil {
+		return nil, err
 	}
 	return c, nil
 }
 
-func (c *BytesConverter) Run() {
-	c.OnCellStart()
+func (c *BytesConverter) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
 	defer c.OnCellFinished()
 
 	for {
@@ -83,51 +92,132 @@ func (c *BytesConverter) Run() {
 		if !ok {
 			break
 		}
-		var err error = nil
 		switch reflect.TypeOf(unit.Data()) {
 		case icell.FormatToType[icell.BYTE_SLICE]:
-			c.process(unit.Data().([]byte))
+			c.process(unit.Data().([]byte), unit.Metadata())
+			// the packets are copied, the buffer can be reused by the reader
+			icell.Release(unit)
 		default:
-			fmt.Printf("Invalid input type %v for BytesConverter", reflect.TypeOf(unit.Data()))
-			err = errinfo.ErrInvalidUnitFormat
-		}
-		if err != nil {
-			break
+			return fmt.Errorf("%w: %v", errinfo.ErrInvalidUnitFormat, reflect.TypeOf(unit.Data()))
 		}
 	}
+
+	// the packets remaining at the end of the stream
+	c.sync.Flush()
+	c.emit(0)
+	c.showResult()
+	return nil
+}
+
+func (c *BytesConverter) process(buffer []byte, metadata *icell.Metadata) {
+	switch c.outputFormat {
+	case icell.TS_PACKET, icell.TS_PACKETS:
+		c.chunks = append(c.chunks, chunkMetadata{start: c.received, metadata: metadata})
+		c.received += int64(len(buffer))
+		c.sync.Write(buffer)
+		c.emit(len(buffer)/packet.PacketSize + 1)
+	default:
+		// not support, drop the buffer
+	}
 }
 
-func (c *BytesConverter) process(buffer []byte) {
-	data := make([]byte, 0)
-	if c.remainedBytes != nil {
-		data = append(data, c.remainedBytes...)
-		c.remainedBytes = nil
+// emit sends the packets found by the synchronizer,
+// a batch holds consecutive packets only
+func (c *BytesConverter) emit(capacity int) {
+	var batch []packet.Packet
+	var batchMetadata *icell.Metadata
+	next := int64(0) // offset of the packet following the batch
+	flush := func() {
+		if len(batch) > 0 {
+			c.PutOutput(icell.NewCellUnitWithMetadata(batch, icell.TS_PACKETS, batchMetadata))
+		}
+		batch = nil
 	}
-	data = append(data, buffer...)
-	remain := len(data)
 
-	switch c.outputFormat {
-	case icell.TS_PACKET:
-		reader := bytes.NewReader(data)
+	for {
+		data, offset, ok := c.sync.Next()
+		if lostAt, lost := c.sync.SyncLoss(); lost {
+			fmt.Printf("[%v] TS_sync_loss at offset %v\n", BytesConverterName, lostAt)
+		}
+		if !ok {
+			break
+		}
+		size := len(data)
+		start := ts.SyncByteOffset(size)
 		var pkt packet.Packet
-		for remain >= packet.PacketSize {
-			if _, err := io.ReadFull(reader, pkt[:]); err != nil {
-				break
-			}
-			if err := pkt.CheckErrors(); err != nil {
-				fmt.Println(err)
-				break
-			}
-			remain -= packet.PacketSize
-			c.PutOutput(icell.NewCellUnit(pkt, icell.TS_PACKET))
+		copy(pkt[:], data[start:])
+		// the index counts from the source, not from the packets sent
+		index := c.index
+		c.index++
+		if err := pkt.CheckErrors(); err != nil {
+			c.invalid++
+			continue
+		}
+
+		if c.outputFormat == icell.TS_PACKET {
+			pktMetadata := c.metadataAt(offset, size)
+			pktMetadata.Index = index
+			pktMetadata.Extra = appendExtra(nil, data)
+			c.PutOutput(icell.NewCellUnitWithMetadata(pkt, icell.TS_PACKET, pktMetadata))
+			continue
+		}
+		if batch != nil && offset != next {
+			flush()
+		}
+		if batch == nil {
+			batch = make([]packet.Packet, 0, capacity)
+			batchMetadata = c.metadataAt(offset, size)
+			batchMetadata.Index = index
 		}
+		batch = append(batch, pkt)
+		batchMetadata.Extra = appendExtra(batchMetadata.Extra, data)
+		next = offset + int64(size)
+	}
+	flush()
+}
+
+// appendExtra appends the bytes of the packet around the ts packet,
+// the header of 192-byte packets or the trailer of 204-byte packets
+func appendExtra(extra []byte, data []byte) []byte {
+	switch len(data) {
+	case ts.M2TS_PACKET_SIZE:
+		return append(extra, data[:ts.SyncByteOffset(len(data))]...)
+	case ts.RS_PACKET_SIZE:
+		return append(extra, data[ts.TS_PACKET_SIZE:]...)
 	default:
-		// not support, drop the buffer
-		remain = 0
+		return extra
+	}
+}
+
+// metadataAt returns a copy of the metadata for the packet of the size at the stream offset,
+// the input buffers before it are no longer needed
+func (c *BytesConverter) metadataAt(offset int64, size int) *icell.Metadata {
+	i := 0
+	for i+1 < len(c.chunks) && c.chunks[i+1].start <= offset {
+		i++
 	}
+	c.chunks = c.chunks[i:]
+	if len(c.chunks) == 0 || c.chunks[0].metadata == nil {
+		return &icell.Metadata{Offset: offset, Index: icell.UNKNOWN, PacketSize: packetSize(size)}
+	}
+	m := *c.chunks[0].metadata
+	if m.Offset != icell.UNKNOWN {
+		m.Offset += offset - c.chunks[0].start
+	}
+	m.PacketSize = packetSize(size)
+	return &m
+}
 
-	if remain > 0 {
-		c.remainedBytes = make([]byte, remain)
-		copy(c.remainedBytes, data[len(data)-remain:])
+// packetSize returns the size kept in the metadata, 0 for ts packets
+func packetSize(size int) int {
+	if size == ts.TS_PACKET_SIZE {
+		return 0
 	}
+	return size
+}
+
+func (c *BytesConverter) showResult() {
+	stats := c.sync.Stats()
+	fmt.Printf("[%v] packets: %v, TS_sync_loss: %v, Sync_byte_error: %v, skipped bytes: %v, invalid packets: %v, packet size: %v\n",
+		BytesConverterName, stats.Packets-c.invalid, stats.SyncLosses, stats.SyncByteErrors, stats.SkippedBytes, c.invalid, c.sync.PacketSize())
 }
@@ -0,0 +1,204 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/Comcast/gots/v2/packet"
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
+)
+
+const (
+	PesConverterName string = "pes_converter"
+
+	config_pesconverter_pids string = "pids"
+)
+
+var (
+	pesConverterInputFormats  = []icell.Format{icell.TS_PACKETS, icell.TS_PACKET}
+	pesConverterOutputFormats = []icell.Format{icell.PES_PACKET}
+
+	PesConverterSpec = icell.Spec{
+		Description: "accumulate ts packets into pes packets",
+		Inputs:      []icell.PortSpec{{Name: icell.INPUT_PORT, Formats: pesConverterInputFormats}},
+		Outputs:     []icell.PortSpec{{Name: icell.OUTPUT_PORT, Formats: pesConverterOutputFormats}},
+		Schema: icell.Schema{
+			{Name: config_pesconverter_pids, Type: icell.PROP_INT_LIST, Description: "select pids to convert, split by \",\", every pid carrying pes packets if not provided"},
+		},
+	}
+)
+
+type PesConverter struct {
+	icell.Cell
+
+	pids map[int]bool // empty for every pid
+
+	accumulator ts.Accumulator
+	pending     [ts.MAX_PID + 1]*pendingPes // nil until the first payload unit start of a pes packet
+	index       int64                       // index of the next packet
+	cc          [ts.MAX_PID + 1]int         // continuity_counter of the last packet with payload, -1 before the first
+
+	pes             uint64 // pes packets sent
+	invalid         uint64 // pes packets dropped for a truncated or invalid header
+	discontinuities uint64 // pes packets dropped for a continuity_counter error
+	errors          uint64 // ts packets dropped for invalid headers or payloads
+}
+
+// pendingPes locates the pes packet being accumulated
+type pendingPes struct {
+	metadata *icell.Metadata // of the first ts packet
+	first    int64
+	last     int64
+}
+
+// PesConverterConfig returns the config to convert ts packets of every pid
+func PesConverterConfig() icell.Config {
+	return icell.Config{}
+}
+
+func NewPesConverter(config icell.Config) (icell.ICell, error) {
+	c := &PesConverter{
+		pids:        make(map[int]bool),
+		accumulator: ts.NewAccumulator(),
+	}
+	for pid := range c.cc {
+		c.cc[pid] = -1
+	}
+	c.ICell = c
+	c.Init(config)
+	c.InitPorts(&PesConverterSpec)
+
+	props, err := icell.ParseConfig(PesConverterName, PesConverterSpec.Schema, config)
+	if err != nil {
+		return nil, err
+	}
+	for _, pid := range props.IntList(config_pesconverter_pids) {
+		if pid < 0 || pid > ts.MAX_PID {
+			return nil, fmt.Errorf("%w: invalid pid %v", errinfo.ErrInvalidCellConfig, pid)
+		}
+		c.pids[pid] = true
+	}
+	return c, nil
+}
+
+func (c *PesConverter) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+
+	for {
+		unit, ok := c.GetInput()
+		if !ok {
+			break
+		}
+		metadata := unit.Metadata()
+		if metadata != nil && metadata.Index != icell.UNKNOWN {
+			// count from the source, packets may be dropped in between
+			c.index = metadata.Index
+		}
+		switch reflect.TypeOf(unit.Data()) {
+		case icell.FormatToType[icell.TS_PACKET]:
+			pkt := unit.Data().(packet.Packet)
+			c.process(&pkt, metadata, 0)
+		case icell.FormatToType[icell.TS_PACKETS]:
+			pkts := unit.Data().([]packet.Packet)
+			for i := range pkts {
+				c.process(&pkts[i], metadata, i)
+			}
+		default:
+			return fmt.Errorf("%w: %v", errinfo.ErrInvalidUnitFormat, reflect.TypeOf(unit.Data()))
+		}
+	}
+
+	// the end of the stream ends the pes packets being accumulated
+	for _, result := range c.accumulator.Flush() {
+		c.emit(result, c.pending[result.Pid])
+	}
+	c.showResult()
+	return nil
+}
+
+// process accumulates the nth packet of the unit described by metadata
+func (c *PesConverter) process(pkt *packet.Packet, metadata *icell.Metadata, n int) {
+	index := c.index
+	c.index++
+
+	if err := pkt.CheckErrors(); err != nil {
+		// the accumulator would reset every pid
+		c.errors++
+		return
+	}
+	pid := packet.Pid(pkt)
+	if len(c.pids) > 0 && !c.pids[pid] {
+		return
+	}
+	if pkt.HasPayload() {
+		cc := pkt.ContinuityCounter()
+		if cc == c.cc[pid] {
+			// duplicate packet
+			return
+		}
+		if c.cc[pid] >= 0 && cc != (c.cc[pid]+1)&0x0F && c.pending[pid] != nil {
+			// the partial pes packet is dropped, the accumulated data is discarded at the next payload unit start
+			c.discontinuities++
+			c.pending[pid] = nil
+		}
+		c.cc[pid] = cc
+	}
+	start := pkt.PayloadUnitStartIndicator()
+	if c.pending[pid] == nil && !start {
+		// wait for the first payload unit start
+		return
+	}
+
+	result, ready, err := c.accumulator.Add(*pkt)
+	if ready {
+		c.emit(result, c.pending[pid])
+	}
+	if err != nil {
+		// the accumulator dropped the data of the pid
+		c.errors++
+		c.pending[pid] = nil
+		return
+	}
+	if start {
+		c.pending[pid] = nil
+		if isPesStart(pkt) {
+			c.pending[pid] = &pendingPes{metadata: metadata.PacketAt(n), first: index}
+		}
+	}
+	if c.pending[pid] != nil {
+		c.pending[pid].last = index
+	}
+}
+
+// isPesStart returns whether the payload starts with the pes start code,
+// the other pids carry sections
+func isPesStart(pkt *packet.Packet) bool {
+	payload, err := packet.Payload(pkt)
+	return err == nil && len(payload) >= 3 && payload[0] == 0 && payload[1] == 0 && payload[2] == 1
+}
+
+// emit sends the data accumulated for the pending pes packet
+func (c *PesConverter) emit(result *ts.AccumulatorResult, pending *pendingPes) {
+	if pending == nil {
+		// not a pes packet
+		return
+	}
+	pes, err := ts.ParsePes(result.Pid, result.Data)
+	if err != nil {
+		c.invalid++
+		return
+	}
+	pes.FirstIndex = pending.first
+	pes.LastIndex = pending.last
+	c.pes++
+	c.PutOutput(icell.NewCellUnitWithMetadata(pes, icell.PES_PACKET, pending.metadata))
+}
+
+func (c *PesConverter) showResult() {
+	fmt.Printf("[%v] pes packets: %v, invalid pes packets: %v, discontinuities: %v, packet errors: %v\n",
+		PesConverterName, c.pes, c.invalid, c.discontinuities, c.errors)
+}
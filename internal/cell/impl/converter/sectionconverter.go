@@ -0,0 +1,154 @@
+package converter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/Comcast/gots/v2/packet"
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
+)
+
+const (
+	SectionConverterName string = "section_converter"
+
+	config_sectionconverter_pids string = "pids"
+
+	// pids of the PSI/SI tables, PAT, CAT, TSDT, NIT, SDT, EIT, TDT...
+	MAX_PSI_PID = 0x1F
+)
+
+var (
+	sectionConverterInputFormats  = []icell.Format{icell.TS_PACKETS, icell.TS_PACKET}
+	sectionConverterOutputFormats = []icell.Format{icell.SECTION}
+
+	SectionConverterSpec = icell.Spec{
+		Description: "assemble ts packets into CRC validated sections",
+		Inputs:      []icell.PortSpec{{Name: icell.INPUT_PORT, Formats: sectionConverterInputFormats}},
+		Outputs:     []icell.PortSpec{{Name: icell.OUTPUT_PORT, Formats: sectionConverterOutputFormats}},
+		Schema: icell.Schema{
+			{Name: config_sectionconverter_pids, Type: icell.PROP_INT_LIST, Description: "select pids to assemble, split by \",\", the pids up to 31 and the PMTs listed in the PAT if not provided"},
+		},
+	}
+)
+
+type SectionConverter struct {
+	icell.Cell
+
+	pids   map[int]bool
+	follow bool // add the pids listed in the PAT
+
+	assembler *ts.SectionAssembler
+	metadata  [ts.MAX_PID + 1]*icell.Metadata // of the packet the last section of the pid started in
+	index     int64                           // index of the next packet
+}
+
+// SectionConverterConfig returns the config to assemble the PSI/SI sections
+func SectionConverterConfig() icell.Config {
+	return icell.Config{}
+}
+
+func NewSectionConverter(config icell.Config) (icell.ICell, error) {
+	c := &SectionConverter{
+		pids:      make(map[int]bool),
+		assembler: ts.NewSectionAssembler(),
+	}
+	c.ICell = c
+	c.Init(config)
+	c.InitPorts(&SectionConverterSpec)
+
+	props, err := icell.ParseConfig(SectionConverterName, SectionConverterSpec.Schema, config)
+	if err != nil {
+		return nil, err
+	}
+	if !props.Has(config_sectionconverter_pids) {
+		c.follow = true
+		for pid := 0; pid <= MAX_PSI_PID; pid++ {
+			c.pids[pid] = true
+		}
+	}
+	for _, pid := range props.IntList(config_sectionconverter_pids) {
+		if pid < 0 || pid > ts.MAX_PID {
+			return nil, fmt.Errorf("%w: invalid pid %v", errinfo.ErrInvalidCellConfig, pid)
+		}
+		c.pids[pid] = true
+	}
+	return c, nil
+}
+
+func (c *SectionConverter) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+
+	for {
+		unit, ok := c.GetInput()
+		if !ok {
+			break
+		}
+		metadata := unit.Metadata()
+		if metadata != nil && metadata.Index != icell.UNKNOWN {
+			// count from the source, packets may be dropped in between
+			c.index = metadata.Index
+		}
+		switch reflect.TypeOf(unit.Data()) {
+		case icell.FormatToType[icell.TS_PACKET]:
+			pkt := unit.Data().(packet.Packet)
+			c.process(&pkt, metadata, 0)
+		case icell.FormatToType[icell.TS_PACKETS]:
+			pkts := unit.Data().([]packet.Packet)
+			for i := range pkts {
+				c.process(&pkts[i], metadata, i)
+			}
+		default:
+			return fmt.Errorf("%w: %v", errinfo.ErrInvalidUnitFormat, reflect.TypeOf(unit.Data()))
+		}
+	}
+
+	c.showResult()
+	return nil
+}
+
+// process assembles the nth packet of the unit described by metadata
+func (c *SectionConverter) process(pkt *packet.Packet, metadata *icell.Metadata, n int) {
+	index := c.index
+	c.index++
+
+	pid := packet.Pid(pkt)
+	if !c.pids[pid] {
+		return
+	}
+	// sections start in payload unit start packets only
+	previous := c.metadata[pid]
+	if pkt.PayloadUnitStartIndicator() {
+		c.metadata[pid] = metadata.PacketAt(n)
+	}
+
+	sections, err := c.assembler.Add(pkt, index)
+	if err != nil {
+		location := fmt.Sprintf("packet %v", index)
+		if metadata != nil {
+			location = metadata.PacketAt(n).Location()
+		}
+		fmt.Printf("[%v] %v: %v\n", SectionConverterName, location, err)
+	}
+	for _, section := range sections {
+		sectionMetadata := c.metadata[pid]
+		if section.FirstIndex != index {
+			sectionMetadata = previous
+		}
+		if c.follow && section.Pid == 0 {
+			for _, pmt := range ts.PatPids(section) {
+				c.pids[pmt] = true
+			}
+		}
+		c.PutOutput(icell.NewCellUnitWithMetadata(section, icell.SECTION, sectionMetadata))
+	}
+}
+
+func (c *SectionConverter) showResult() {
+	stats := c.assembler.Stats()
+	fmt.Printf("[%v] sections: %v, CRC_error: %v, invalid sections: %v, discontinuities: %v\n",
+		SectionConverterName, stats.Sections, stats.CrcErrors, stats.Invalid, stats.Discontinuities)
+}
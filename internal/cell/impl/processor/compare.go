@@ -0,0 +1,175 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/Comcast/gots/v2/packet"
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+const (
+	CompareName string = "compare"
+
+	compare_port_a = "a"
+	compare_port_b = "b"
+
+	// maximum mismatched indexes to show
+	compare_max_report = 10
+)
+
+var (
+	compareInputFormats []icell.Format = []icell.Format{icell.TS_PACKETS, icell.TS_PACKET}
+
+	CompareSpec = icell.Spec{
+		Description: "compare two ts streams packet by packet",
+		Inputs: []icell.PortSpec{
+			{Name: compare_port_a, Formats: compareInputFormats},
+			{Name: compare_port_b, Formats: compareInputFormats},
+		},
+	}
+)
+
+type Compare struct {
+	icell.Cell
+
+	identical  int64
+	different  int64
+	mismatches []int64
+	extra      map[string]int64
+}
+
+func NewCompare(config icell.Config) (icell.ICell, error) {
+	c := &Compare{
+		mismatches: make([]int64, 0),
+		extra:      make(map[string]int64),
+	}
+	c.ICell = c
+	c.Init(config)
+	c.InitPorts(&CompareSpec)
+
+	if _, err := icell.ParseConfig(CompareName, CompareSpec.Schema, config); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Compare) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+
+	a := &compareInput{c: c, port: compare_port_a}
+	b := &compareInput{c: c, port: compare_port_b}
+	defer a.release()
+	defer b.release()
+
+	index := int64(0)
+	for {
+		pktA, okA, err := a.next()
+		if err != nil {
+			return err
+		}
+		pktB, okB, err := b.next()
+		if err != nil {
+			return err
+		}
+		if !okA || !okB {
+			if okA {
+				cnt, err := a.drain()
+				if err != nil {
+					return err
+				}
+				c.extra[compare_port_a] = 1 + cnt
+			}
+			if okB {
+				cnt, err := b.drain()
+				if err != nil {
+					return err
+				}
+				c.extra[compare_port_b] = 1 + cnt
+			}
+			break
+		}
+		if pktA == pktB {
+			c.identical++
+		} else {
+			c.different++
+			if len(c.mismatches) < compare_max_report {
+				c.mismatches = append(c.mismatches, index)
+			}
+		}
+		index++
+	}
+
+	c.showResult()
+	return nil
+}
+
+// compareInput reads the packets of a port one by one, from single packets or batches
+type compareInput struct {
+	c    *Compare
+	port string
+
+	unit    icell.CellUnit // holding the packets
+	packets []packet.Packet
+	single  [1]packet.Packet
+}
+
+// next returns the next packet of the port, false if the port is closed
+func (in *compareInput) next() (packet.Packet, bool, error) {
+	for len(in.packets) == 0 {
+		in.release()
+		unit, ok := in.c.GetPortInput(in.port)
+		if !ok {
+			return packet.Packet{}, false, nil
+		}
+		in.unit = unit
+		switch reflect.TypeOf(unit.Data()) {
+		case icell.FormatToType[icell.TS_PACKET]:
+			in.single[0] = unit.Data().(packet.Packet)
+			in.packets = in.single[:]
+		case icell.FormatToType[icell.TS_PACKETS]:
+			in.packets = unit.Data().([]packet.Packet)
+		default:
+			return packet.Packet{}, false, fmt.Errorf("%w: %v", errinfo.ErrInvalidUnitFormat, reflect.TypeOf(unit.Data()))
+		}
+	}
+	pkt := in.packets[0]
+	in.packets = in.packets[1:]
+	return pkt, true, nil
+}
+
+// drain consumes the rest of the port and returns the number of packets
+func (in *compareInput) drain() (int64, error) {
+	cnt := int64(0)
+	for {
+		_, ok, err := in.next()
+		if !ok || err != nil {
+			return cnt, err
+		}
+		cnt++
+	}
+}
+
+func (in *compareInput) release() {
+	if in.unit != nil {
+		icell.Release(in.unit)
+		in.unit = nil
+	}
+}
+
+func (c *Compare) showResult() {
+	fmt.Println("[compare] result")
+	fmt.Printf("  identical packets: %v\n", c.identical)
+	fmt.Printf("  different packets: %v\n", c.different)
+	if len(c.mismatches) > 0 {
+		fmt.Printf("  first different indexes: %v\n", c.mismatches)
+	}
+	for _, port := range []string{compare_port_a, compare_port_b} {
+		if c.extra[port] > 0 {
+			fmt.Printf("  extra packets on %v: %v\n", port, c.extra[port])
+		}
+	}
+}
@@ -2,13 +2,14 @@ package processor
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"reflect"
 	"strconv"
-	"strings"
 
 	"github.com/Comcast/gots/v2/packet"
 	"github.com/Comcast/gots/v2/pes"
@@ -30,35 +31,20 @@ const (
 )
 
 var (
-	vbvInputFormats  []icell.Format = []icell.Format{icell.TS_PACKET}
-	vbvOutputFormats []icell.Format = nil
+	vbvInputFormats []icell.Format = []icell.Format{icell.TS_PACKETS, icell.TS_PACKET}
+
+	VbvSpec = icell.Spec{
+		Description: "calculate dts-pcr",
+		Inputs:      []icell.PortSpec{{Name: icell.INPUT_PORT, Formats: vbvInputFormats}},
+		Schema: icell.Schema{
+			{Name: config_vbv_pids, Type: icell.PROP_INT_LIST, Required: true, Description: "select pids to process, split by \",\""},
+			{Name: config_vbv_pcr, Type: icell.PROP_INT, Required: true, Description: "pcr pid"},
+			{Name: config_vbv_dir, Type: icell.PROP_STRING, Description: "output directory, output to console if not provided"},
+			{Name: config_vbv_plot, Type: icell.PROP_BOOL, Default: "false", Description: "plot the result"},
+		},
+	}
 )
 
-func VbvHelp() {
-	VbvHelpShort()
-	format := `	IO:
-	  ->cell: %v
-	  cell->: %v
-	Properties:
-	  %v: select pids to process, split by ","
-	  %v: pcr pid
-	  %v: output directory
-	  %v: plot the result
-`
-	fmt.Printf(format,
-		vbvInputFormats,
-		vbvOutputFormats,
-		config_vbv_pids,
-		config_vbv_pcr,
-		config_vbv_dir,
-		config_vbv_plot,
-	)
-}
-
-func VbvHelpShort() {
-	fmt.Printf("%v : calculate dts-pcr\n", VbvName)
-}
-
 type Record struct {
 	Pid    int
 	Index  int64
@@ -95,7 +81,7 @@ type Vbv struct {
 	vbvs [ts.MAX_PID + 1][]*VbvRecord
 }
 
-func NewVbv(stopChan chan bool, config icell.Config) (icell.ICell, error) {
+func NewVbv(config icell.Config) (icell.ICell, error) {
 	c := &Vbv{
 		accumulator:    ts.NewAccumulator(),
 		pids:           make(map[int]bool),
@@ -103,93 +89,109 @@ func NewVbv(stopChan chan bool, config icell.Config) (icell.ICell, error) {
 		pendingRecords: make([]*Record, 0),
 	}
 	c.ICell = c
-	c.Init(stopChan, config)
+	c.Init(config)
+	c.InitPorts(&VbvSpec)
 
-	var err error
-	if pcrStr, ok := config[config_vbv_pcr]; ok {
-		if c.pcr, err = strconv.Atoi(pcrStr); err != nil {
-			fmt.Println("[vbv] invalid pcr pid", pcrStr)
-			return nil, errinfo.ErrInvalidCellConfig
-		}
-	} else {
-		fmt.Println("[vbv] missing pcr pid")
-		return nil, errinfo.ErrInvalidCellConfig
+	props, err := icell.ParseConfig(VbvName, VbvSpec.Schema, config)
+	if err != nil {
+		return nil, err
 	}
 
-	if pidsStr, ok := config[config_vbv_pids]; ok {
-		pidStrs := strings.Split(pidsStr, ",")
-		if len(pidStrs) < 1 {
-			fmt.Println("[vbv] missing processing pids")
-			return nil, errinfo.ErrInvalidCellConfig
-		}
-		for _, pidStr := range pidStrs {
-			var pid int
-			if pid, err = strconv.Atoi(pidStr); err != nil {
-				fmt.Println("[vbv] invalid processing pid", pidStr)
-				return nil, errinfo.ErrInvalidCellConfig
-			}
-			c.pids[pid] = true
-			c.vbvs[pid] = make([]*VbvRecord, 0)
-			c.curVbv[pid] = nil
+	c.pcr = props.Int(config_vbv_pcr)
+	if !validPid(c.pcr) {
+		return nil, fmt.Errorf("%w: invalid pcr pid %v", errinfo.ErrInvalidCellConfig, c.pcr)
+	}
+	for _, pid := range props.IntList(config_vbv_pids) {
+		if !validPid(pid) {
+			return nil, fmt.Errorf("%w: invalid processing pid %v", errinfo.ErrInvalidCellConfig, pid)
 		}
-	} else {
-		fmt.Println("[vbv] missing processing pids")
-		return nil, errinfo.ErrInvalidCellConfig
+		c.pids[pid] = true
+		c.vbvs[pid] = make([]*VbvRecord, 0)
+		c.curVbv[pid] = nil
 	}
 
-	if dir, ok := config[config_vbv_dir]; ok {
-		c.outputDir = dir
-	} else {
-		fmt.Println("[vbv] output to console")
-	}
+	c.outputDir = props.String(config_vbv_dir)
+	c.plot = props.Bool(config_vbv_plot)
 
-	if plot, ok := config[config_vbv_plot]; ok {
-		if plot == "true" {
-			c.plot = true
-			fmt.Println("[vbv] plot the result")
-		}
+	return c, nil
+}
+
+func (c *Vbv) Check() error {
+	if c.outputDir == "" {
+		return nil
 	}
+	return icell.CheckWritableDir(c.outputDir)
+}
 
-	return c, nil
+func validPid(pid int) bool {
+	return pid >= 0 && pid <= ts.MAX_PID
 }
 
-func (c *Vbv) Run() {
-	defer c.OnCellFinished()
-	c.OnCellStart()
+func (c *Vbv) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
 	defer c.OnCellFinished()
 
+	if c.outputDir == "" {
+		fmt.Println("[vbv] output to console")
+	}
+	if c.plot {
+		fmt.Println("[vbv] plot the result")
+	}
+
+	var err error
 	index := int64(0)
-workLoop:
 	for {
 		unit, ok := c.GetInput()
 		if !ok {
 			break
 		}
+		metadata := unit.Metadata()
+		if metadata != nil && metadata.Index != icell.UNKNOWN {
+			// count from the source, packets may be dropped in between
+			index = metadata.Index
+		}
+		n := 0
 		switch reflect.TypeOf(unit.Data()) {
 		case icell.FormatToType[icell.TS_PACKET]:
-			data := unit.Data().(packet.Packet)
-			if !c.processPkt(data, index) {
-				break workLoop
-			}
-			if !c.processPcrPkt(data, index) {
-				break workLoop
+			err = c.process(unit.Data().(packet.Packet), index)
+		case icell.FormatToType[icell.TS_PACKETS]:
+			for _, pkt := range unit.Data().([]packet.Packet) {
+				if err = c.process(pkt, index+int64(n)); err != nil {
+					break
+				}
+				n++
 			}
 		default:
-			fmt.Println("[vbv] invalid input format")
+			err = fmt.Errorf("%w: %v", errinfo.ErrInvalidUnitFormat, reflect.TypeOf(unit.Data()))
+		}
+		if err != nil {
+			if metadata != nil {
+				err = fmt.Errorf("%v: %w", metadata.PacketAt(n).Location(), err)
+			} else {
+				err = fmt.Errorf("packet %v: %w", index+int64(n), err)
+			}
+			break
 		}
-		index++
+		index += int64(max(n, 1))
 	}
 
-	c.showResult()
+	// show the result processed so far even if aborted
+	return errors.Join(err, c.showResult())
 }
 
-func (c *Vbv) processPkt(pkt packet.Packet, index int64) bool {
+func (c *Vbv) process(pkt packet.Packet, index int64) error {
+	if err := c.processPkt(pkt, index); err != nil {
+		return err
+	}
+	return c.processPcrPkt(pkt, index)
+}
+
+func (c *Vbv) processPkt(pkt packet.Packet, index int64) error {
 	if err := pkt.CheckErrors(); err != nil {
-		fmt.Println("[vbv] packet error", err)
-		return false
+		return fmt.Errorf("packet error: %w", err)
 	}
 	if _, ok := c.pids[packet.Pid(&pkt)]; !ok {
-		return true
+		return nil
 	}
 	c.pendingRecords = append(c.pendingRecords, &Record{
 		Pid:    packet.Pid(&pkt),
@@ -198,26 +200,20 @@ func (c *Vbv) processPkt(pkt packet.Packet, index int64) bool {
 		Packet: pkt,
 	})
 
-	return true
+	return nil
 }
 
-func (c *Vbv) processPcrPkt(pkt packet.Packet, index int64) bool {
-	if err := pkt.CheckErrors(); err != nil {
-		fmt.Println("[vbv] packet error", err)
-		return false
-	}
+func (c *Vbv) processPcrPkt(pkt packet.Packet, index int64) error {
 	if packet.Pid(&pkt) == c.pcr && packet.ContainsAdaptationField(&pkt) {
 		af := packet.AdaptationField(pkt)
 		hasPcr, err := af.HasPCR()
 		if err != nil {
-			fmt.Println(err)
-			return false
+			return err
 		}
 		if hasPcr {
 			pcr, err := af.PCR()
 			if err != nil {
-				fmt.Println(err)
-				return false
+				return err
 			}
 
 			newPcr := &PcrRecord{
@@ -235,18 +231,18 @@ func (c *Vbv) processPcrPkt(pkt packet.Packet, index int64) bool {
 				}
 				// all the pending packets have pcr now
 				// ready to process
-				if !c.processPendingPkts() {
-					return false
+				if err := c.processPendingPkts(); err != nil {
+					return err
 				}
 			}
 			c.lastPcr = newPcr
 
 		}
 	}
-	return true
+	return nil
 }
 
-func (c *Vbv) processPendingPkts() bool {
+func (c *Vbv) processPendingPkts() error {
 	for _, record := range c.pendingRecords {
 		pkt := record.Packet
 		index := record.Index
@@ -271,14 +267,12 @@ func (c *Vbv) processPendingPkts() bool {
 
 		result, ready, err := c.accumulator.Add(pkt)
 		if err != nil {
-			fmt.Println("[vbv] accumulator error", err)
-			return false
+			return fmt.Errorf("accumulator error: %w", err)
 		}
 		if ready {
 			pes, err := pes.NewPESHeader(result.Data)
 			if err != nil {
-				fmt.Println("[vbv] pes error", err)
-				return false
+				return fmt.Errorf("pes error: %w", err)
 			}
 			if pes.HasDTS() {
 				c.curVbv[pid].Dts = int64(pes.DTS())
@@ -301,16 +295,16 @@ func (c *Vbv) processPendingPkts() bool {
 		c.curVbv[pid].EndPcr = pcr
 	}
 	c.pendingRecords = c.pendingRecords[:0]
-	return true
+	return nil
 }
 
-func (c *Vbv) showResult() {
+func (c *Vbv) showResult() error {
 	if c.outputDir != "" {
 		if err := os.MkdirAll(c.outputDir, 0755); err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
 	}
+	errs := make([]error, 0)
 	for pid := 0; pid < ts.MAX_PID; pid++ {
 		if len(c.vbvs[pid]) == 0 {
 			continue
@@ -320,7 +314,7 @@ func (c *Vbv) showResult() {
 			filename := path.Join(c.outputDir, fmt.Sprintf("vbv_%v.txt", pid))
 			file, err := os.Create(filename)
 			if err != nil {
-				fmt.Println(err)
+				errs = append(errs, err)
 				continue
 			}
 			defer file.Close()
@@ -336,7 +330,7 @@ func (c *Vbv) showResult() {
 			plotFilename := path.Join(c.outputDir, fmt.Sprintf("vbv_%v.html", pid))
 			plotFile, err := os.Create(plotFilename)
 			if err != nil {
-				fmt.Println(err)
+				errs = append(errs, err)
 				continue
 			}
 			defer plotFile.Close()
@@ -357,11 +351,11 @@ func (c *Vbv) showResult() {
 		}
 
 		if _, err := writer.WriteString(fmt.Sprintf("pid %v\n", pid)); err != nil {
-			fmt.Println(err)
+			errs = append(errs, err)
 			continue
 		}
 		if _, err := writer.WriteString("  [ index , endIndex ] dts -> pcr vbv\n"); err != nil {
-			fmt.Println(err)
+			errs = append(errs, err)
 			continue
 		}
 		for _, vbv := range c.vbvs[pid] {
@@ -371,7 +365,7 @@ func (c *Vbv) showResult() {
 				})
 			}
 			if _, err := writer.WriteString(fmt.Sprintf("  [ %v , %v ] %v -> %v %v\n", vbv.Index, vbv.EndIndex, vbv.Dts, vbv.EndPcr/300, vbv.Dts-vbv.EndPcr/300)); err != nil {
-				fmt.Println(err)
+				errs = append(errs, err)
 				break
 			}
 		}
@@ -386,8 +380,9 @@ func (c *Vbv) showResult() {
 					),
 				)
 			if err := lineChart.Render(plotWriter); err != nil {
-				fmt.Println(err)
+				errs = append(errs, err)
 			}
 		}
 	}
+	return errors.Join(errs...)
 }
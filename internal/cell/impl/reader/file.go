@@ -1,13 +1,12 @@
 package reader
 
 import (
-	"bufio"
-	"fmt"
+	"context"
+	"io"
 	"os"
-	"strconv"
+	"sync"
 
 	"github.com/potterxu/tsanalyzer/internal/cell/icell"
-	"github.com/potterxu/tsanalyzer/internal/errinfo"
 )
 
 const (
@@ -16,12 +15,28 @@ const (
 	config_filereader_size = "size"
 	config_filereader_name = "name"
 
-	chunk_size int = 1 << 10
+	chunk_size int = 1 << 16
 )
 
 var (
-	fileReaderInputFormats  []icell.Format = nil
+	// buffers are returned to the pool when released by every consumer
+	fileReaderBuffers = sync.Pool{
+		New: func() any {
+			buffer := make([]byte, chunk_size)
+			return &buffer
+		},
+	}
+
 	fileReaderOutputFormats []icell.Format = []icell.Format{icell.BYTE_SLICE}
+
+	FileReaderSpec = icell.Spec{
+		Description: "read content from file",
+		Outputs:     []icell.PortSpec{{Name: icell.OUTPUT_PORT, Formats: fileReaderOutputFormats}},
+		Schema: icell.Schema{
+			{Name: config_filereader_name, Type: icell.PROP_STRING, Required: true, Description: "filename to read from"},
+			{Name: config_filereader_size, Type: icell.PROP_UINT, Default: "0", Description: "total bytes to read, 0 to read all"},
+		},
+	}
 )
 
 type FileReader struct {
@@ -31,78 +46,63 @@ type FileReader struct {
 	total    uint64
 }
 
-func FileReaderHelp() {
-	FileReaderHelpShort()
-	format := `	IO:
-	  ->cell: %v
-	  cell->: %v
-	Properties:
-	  %v: filename to read from
-	  %v: optional, total bytes to read
-`
-	fmt.Printf(format,
-		fileReaderInputFormats,
-		fileReaderOutputFormats,
-		config_filereader_name,
-		config_filereader_size)
-}
-
-func FileReaderHelpShort() {
-	fmt.Printf("%v : read content from file\n", FileReaderName)
-}
-
-func NewFileReader(stopChan chan bool, config icell.Config) (icell.ICell, error) {
+func NewFileReader(config icell.Config) (icell.ICell, error) {
 	c := &FileReader{
 		total: 0,
 	}
 	c.ICell = c
-	c.Init(stopChan, config)
-
-	if filename, ok := config[config_filereader_name]; ok {
-		c.filename = filename
-	} else {
-		fmt.Println("file name not provided for FileReader")
-		FileReaderHelp()
-		return nil, errinfo.ErrInvalidCellConfig
-	}
+	c.Init(config)
+	c.InitPorts(&FileReaderSpec)
 
-	if tStr, ok := config[config_filereader_size]; ok {
-		t, err := strconv.ParseUint(tStr, 10, 64)
-		if err != nil {
-			fmt.Println("[file_reader] invalid size", tStr)
-			return nil, errinfo.ErrInvalidCellConfig
-		}
-		c.total = t
+	props, err := icell.ParseConfig(FileReaderName, FileReaderSpec.Schema, config)
+	if err != nil {
+		return nil, err
 	}
+	c.filename = props.String(config_filereader_name)
+	c.total = props.Uint(config_filereader_size)
 
 	return c, nil
 }
 
-func (c *FileReader) Run() {
-	c.OnCellStart()
+func (c *FileReader) Check() error {
+	return icell.CheckReadable(c.filename)
+}
+
+func (c *FileReader) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
 	defer c.OnCellFinished()
 
 	file, err := os.Open(c.filename)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer file.Close()
+	// unblock reading from pipes or slow devices when aborted
+	stopClose := context.AfterFunc(ctx, func() { file.Close() })
+	defer stopClose()
 
-	reader := bufio.NewReader(file)
 	readBytes := uint64(0)
 	for c.Running() {
-		buffer := make([]byte, chunk_size)
-		cnt, err := reader.Read(buffer)
+		buffer := fileReaderBuffers.Get().(*[]byte)
+		free := func() { fileReaderBuffers.Put(buffer) }
+		cnt, err := file.Read(*buffer)
 		if err != nil {
-			break
+			free()
+			if err == io.EOF || ctx.Err() != nil {
+				break
+			}
+			return err
 		}
+		metadata := icell.NewMetadata(c.Id())
+		metadata.Offset = int64(readBytes)
 		if c.total > 0 && readBytes+uint64(cnt) >= c.total {
 			// reach maximum read size
 			cnt = int(c.total - readBytes)
-			c.PutOutput(icell.NewCellUnit(buffer[:cnt], icell.BYTE_SLICE))
+			c.PutOutput(icell.NewPooledCellUnit((*buffer)[:cnt], icell.BYTE_SLICE, metadata, free))
 			break
 		}
-		c.PutOutput(icell.NewCellUnit(buffer[:cnt], icell.BYTE_SLICE))
+		c.PutOutput(icell.NewPooledCellUnit((*buffer)[:cnt], icell.BYTE_SLICE, metadata, free))
 		readBytes += uint64(cnt)
 	}
+	return nil
 }
@@ -0,0 +1,315 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/tsutil/hls"
+)
+
+const (
+	HlsReaderName string = "hls_reader"
+
+	config_hlsreader_url       string = "url"
+	config_hlsreader_bandwidth string = "bandwidth"
+
+	// segments from the end of a live playlist to start with, RFC 8216 6.3.3
+	live_start_segments = 3
+)
+
+var (
+	hlsReaderOutputFormats []icell.Format = []icell.Format{icell.BYTE_SLICE}
+
+	HlsReaderSpec = icell.Spec{
+		Description: "read the segments of a hls playlist, vod or live, from http or local files",
+		Outputs:     []icell.PortSpec{{Name: icell.OUTPUT_PORT, Formats: hlsReaderOutputFormats}},
+		Schema: append(icell.Schema{
+			{Name: config_hlsreader_url, Type: icell.PROP_STRING, Required: true, Description: "master or media playlist, e.g. \"http://origin/live/master.m3u8\" or a local file"},
+			{Name: config_hlsreader_bandwidth, Type: icell.PROP_UINT, Default: "0", Description: "select the highest variant within the bandwidth in bits per second, the lowest if none fits, 0 for the highest variant"},
+		}, streamSchemaWith(icell.Property{
+			Name: config_stream_retries, Type: icell.PROP_INT,
+			Description: "attempts after a request failed, -1 forever, by default none for a vod playlist, forever for a live playlist and for a target duration for its segments",
+		})...),
+	}
+)
+
+type hlsReader struct {
+	icell.Cell
+
+	url       string
+	bandwidth uint64
+	client    *http.Client
+	stream    *streamReader
+
+	retriesSet bool // retries configured, else a live playlist is retried by default
+	live       bool // the media playlist has no end list
+	target     time.Duration
+
+	segments        uint64
+	discontinuities uint64
+	missed          uint64 // segments removed from a live playlist before they were downloaded, or skipped after failing
+	reloads         uint64
+}
+
+func NewHlsReader(config icell.Config) (icell.ICell, error) {
+	c := &hlsReader{}
+	c.ICell = c
+	c.Init(config)
+	c.InitPorts(&HlsReaderSpec)
+
+	props, err := icell.ParseConfig(HlsReaderName, HlsReaderSpec.Schema, config)
+	if err != nil {
+		return nil, err
+	}
+	c.url = props.String(config_hlsreader_url)
+	c.bandwidth = props.Uint(config_hlsreader_bandwidth)
+	_, c.retriesSet = config[config_stream_retries]
+	if c.stream, err = newStreamReader(HlsReaderName, props); err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: c.stream.timeout}).DialContext
+	transport.ResponseHeaderTimeout = c.stream.timeout
+	c.client = &http.Client{Transport: transport}
+	return c, nil
+}
+
+func (c *hlsReader) Check() error {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return nil
+	case "", "file":
+		return icell.CheckReadable(u.Path)
+	}
+	return fmt.Errorf("%v is not a http url or a file", c.url)
+}
+
+func (c *hlsReader) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+	defer c.showResult()
+
+	location, err := url.Parse(c.url)
+	if err != nil {
+		return err
+	}
+	playlist, err := c.load(ctx, location)
+	if err != nil || playlist == nil {
+		return err
+	}
+	if playlist.IsMaster() {
+		variant := selectVariant(playlist.Variants, c.bandwidth)
+		if location, err = resolve(location, variant.URI); err != nil {
+			return err
+		}
+		fmt.Printf("[%v] variant: %v, bandwidth: %v\n", HlsReaderName, location, variant.Bandwidth)
+		if playlist, err = c.load(ctx, location); err != nil || playlist == nil {
+			return err
+		}
+		if playlist.IsMaster() {
+			return fmt.Errorf("%v is not a media playlist", location)
+		}
+	}
+
+	next := icell.UNKNOWN // media sequence number of the next segment to download
+	for c.Running() {
+		c.live, c.target = !playlist.Ended, playlist.TargetDuration
+		segments := playlist.Segments
+		if next == icell.UNKNOWN && !playlist.Ended {
+			segments = segments[max(0, len(segments)-live_start_segments):]
+		}
+		for len(segments) > 0 && next != icell.UNKNOWN && segments[0].Sequence < next {
+			segments = segments[1:]
+		}
+		if len(segments) > 0 && next != icell.UNKNOWN && segments[0].Sequence > next {
+			missed := segments[0].Sequence - next
+			c.missed += uint64(missed)
+			fmt.Printf("[%v] %v segments missed before segment %v\n", HlsReaderName, missed, segments[0].Sequence)
+		}
+		for i := range segments {
+			if err := c.download(ctx, location, &segments[i]); err != nil || !c.Running() {
+				return err
+			}
+			next = segments[i].Sequence + 1
+		}
+		if playlist.Ended {
+			return nil
+		}
+
+		// reload after the target duration, or half of it if the playlist did not change, RFC 8216 6.3.4
+		wait := playlist.TargetDuration
+		if len(segments) == 0 {
+			wait /= 2
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		if playlist, err = c.load(ctx, location); err != nil || playlist == nil {
+			return err
+		}
+		c.reloads++
+	}
+	return nil
+}
+
+// selectVariant returns the variant of the highest bandwidth within the bandwidth, the lowest if none fits
+func selectVariant(variants []hls.Variant, bandwidth uint64) hls.Variant {
+	selected, lowest := -1, 0
+	for i, v := range variants {
+		if v.Bandwidth < variants[lowest].Bandwidth {
+			lowest = i
+		}
+		if (bandwidth == 0 || v.Bandwidth <= bandwidth) && (selected < 0 || v.Bandwidth > variants[selected].Bandwidth) {
+			selected = i
+		}
+	}
+	if selected < 0 {
+		return variants[lowest]
+	}
+	return variants[selected]
+}
+
+// resolve returns the location of the uri of a playlist at base
+func resolve(base *url.URL, uri string) (*url.URL, error) {
+	ref, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if isLocal(base) && ref.Scheme == "" && !filepath.IsAbs(ref.Path) {
+		// relative file paths are not rooted as url paths
+		local := *base
+		local.Path = filepath.Join(filepath.Dir(base.Path), ref.Path)
+		return &local, nil
+	}
+	return base.ResolveReference(ref), nil
+}
+
+func isLocal(u *url.URL) bool {
+	return u.Scheme == "" || u.Scheme == "file"
+}
+
+// load returns the playlist at location, nil if the cell is stopped
+func (c *hlsReader) load(ctx context.Context, location *url.URL) (*hls.Playlist, error) {
+	var playlist *hls.Playlist
+	retries := c.stream.retries
+	if c.live && !c.retriesSet {
+		retries = -1
+	}
+	err := c.retry(ctx, retries, func() error {
+		s, err := c.open(ctx, location)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		playlist, err = hls.Parse(s)
+		return err
+	})
+	return playlist, err
+}
+
+/* download sends the data of the segment, a segment partially received is not downloaded again,
+ * a segment of a live playlist failing for a target duration is skipped unless retries is configured
+ */
+func (c *hlsReader) download(ctx context.Context, base *url.URL, segment *hls.Segment) error {
+	location, err := resolve(base, segment.URI)
+	if err != nil {
+		return err
+	}
+	if segment.Discontinuity {
+		c.discontinuities++
+		fmt.Printf("[%v] discontinuity at segment %v\n", HlsReaderName, segment.Sequence)
+	}
+	retries := c.stream.retries
+	skip := c.live && !c.retriesSet
+	if skip {
+		retries = max(1, int(c.target/max(c.stream.delay, time.Millisecond)))
+	}
+	err = c.retry(ctx, retries, func() error {
+		s, err := c.open(ctx, location)
+		if err != nil {
+			return err
+		}
+		s.origin = &SegmentOrigin{Remote: s.remote, Segment: segment, Start: int64(c.stream.bytes)}
+		c.stream.connections++
+		received, err := c.stream.read(&c.Cell, s)
+		if received && err != nil {
+			c.stream.failures++
+			fmt.Printf("[%v] segment %v incomplete: %v\n", HlsReaderName, segment.Sequence, err)
+			return nil
+		}
+		if s.eof {
+			c.segments++
+		}
+		return err
+	})
+	if err != nil && skip {
+		c.missed++
+		fmt.Printf("[%v] segment %v skipped\n", HlsReaderName, segment.Sequence)
+		return nil
+	}
+	return err
+}
+
+// retry calls attempt until it succeeds, the retries are exhausted or the cell is stopped, -1 retries forever
+func (c *hlsReader) retry(ctx context.Context, retries int, attempt func() error) error {
+	for attempts := 0; c.Running(); attempts++ {
+		err := attempt()
+		if err == nil || !c.Running() || ctx.Err() != nil {
+			return nil
+		}
+		c.stream.failures++
+		fmt.Printf("[%v] error: %v\n", HlsReaderName, err)
+		if retries >= 0 && attempts >= retries {
+			return err
+		}
+		fmt.Printf("[%v] retry in %v, attempt %v\n", HlsReaderName, c.stream.delay, attempts+1)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(c.stream.delay):
+		}
+	}
+	return nil
+}
+
+// open the playlist or the segment at location
+func (c *hlsReader) open(ctx context.Context, location *url.URL) (*stream, error) {
+	if isLocal(location) {
+		file, err := os.Open(location.Path)
+		if err != nil {
+			return nil, err
+		}
+		return &stream{ReadCloser: file}, nil
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("%v: %v", location, response.Status)
+	}
+	return &stream{ReadCloser: response.Body, remote: request.URL.Host}, nil
+}
+
+func (c *hlsReader) showResult() {
+	fmt.Printf("[%v] segments: %v, discontinuities: %v, missed: %v, reloads: %v\n", HlsReaderName, c.segments, c.discontinuities, c.missed, c.reloads)
+	c.stream.showResult()
+}
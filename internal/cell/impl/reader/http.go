@@ -0,0 +1,93 @@
+package reader
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+)
+
+const (
+	HttpReaderName string = "http_reader"
+
+	config_httpreader_url string = "url"
+)
+
+var (
+	httpReaderOutputFormats []icell.Format = []icell.Format{icell.BYTE_SLICE}
+
+	HttpReaderSpec = icell.Spec{
+		Description: "read a progressive stream over http",
+		Outputs:     []icell.PortSpec{{Name: icell.OUTPUT_PORT, Formats: httpReaderOutputFormats}},
+		Schema: append(icell.Schema{
+			{Name: config_httpreader_url, Type: icell.PROP_STRING, Required: true, Description: "url to get, e.g. \"http://encoder/preview.ts\""},
+		}, streamSchema...),
+	}
+)
+
+type httpReader struct {
+	icell.Cell
+
+	url    string
+	client *http.Client
+	stream *streamReader
+}
+
+func NewHttpReader(config icell.Config) (icell.ICell, error) {
+	c := &httpReader{}
+	c.ICell = c
+	c.Init(config)
+	c.InitPorts(&HttpReaderSpec)
+
+	props, err := icell.ParseConfig(HttpReaderName, HttpReaderSpec.Schema, config)
+	if err != nil {
+		return nil, err
+	}
+	c.url = props.String(config_httpreader_url)
+	if c.stream, err = newStreamReader(HttpReaderName, props); err != nil {
+		return nil, err
+	}
+	// the body is read as long as the stream lasts, only connecting is limited
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: c.stream.timeout}).DialContext
+	transport.ResponseHeaderTimeout = c.stream.timeout
+	c.client = &http.Client{Transport: transport}
+	return c, nil
+}
+
+func (c *httpReader) Check() error {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%v is not a http url", c.url)
+	}
+	return nil
+}
+
+func (c *httpReader) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+
+	return c.stream.run(&c.Cell, c.open)
+}
+
+func (c *httpReader) open(ctx context.Context) (*stream, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("%v: %v", c.url, response.Status)
+	}
+	return &stream{ReadCloser: response.Body, remote: request.URL.Host}, nil
+}
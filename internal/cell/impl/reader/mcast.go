@@ -1,11 +1,9 @@
 package reader
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"net"
-	"os"
-	"time"
 
 	"github.com/potterxu/tsanalyzer/internal/cell/icell"
 )
@@ -18,100 +16,80 @@ const (
 )
 
 var (
-	mcastReaderInputFormats  []icell.Format = nil
 	mcastReaderOutputFormats []icell.Format = []icell.Format{icell.BYTE_SLICE}
+
+	McastReaderSpec = icell.Spec{
+		Description: "read udp payload from multicast, RTP/MP2T or raw",
+		Outputs:     []icell.PortSpec{{Name: icell.OUTPUT_PORT, Formats: mcastReaderOutputFormats}},
+		Schema: icell.Schema{
+			{Name: config_mcastreader_interface, Type: icell.PROP_STRING, Required: true, Description: "interface name"},
+			{Name: config_mcastreader_address, Type: icell.PROP_STRING, Required: true, Description: "multicast address, e.g \"239.1.1.1:1000\""},
+			rtpProperty,
+		},
+	}
 )
 
-func NewMcastReader(stopChan chan bool, config icell.Config) (icell.ICell, error) {
+func NewMcastReader(config icell.Config) (icell.ICell, error) {
 	c := &mcastReader{}
 	c.ICell = c
-	c.Init(stopChan, config)
+	c.Init(config)
+	c.InitPorts(&McastReaderSpec)
 
-	if intf, ok := config[config_mcastreader_interface]; ok {
-		c.intfName = intf
-	} else {
-		return nil, errors.New("interface name not found")
-	}
-
-	if addr, ok := config[config_mcastreader_address]; ok {
-		c.address = addr
-	} else {
-		return nil, errors.New("multicast address not found")
+	props, err := icell.ParseConfig(McastReaderName, McastReaderSpec.Schema, config)
+	if err != nil {
+		return nil, err
 	}
+	c.intfName = props.String(config_mcastreader_interface)
+	c.address = props.String(config_mcastreader_address)
+	c.rtp = newRtpReceiver(McastReaderName, props.String(config_rtp))
 
 	return c, nil
 }
 
-func McastReaderHelp() {
-	McastReaderHelpShort()
-	format := `	IO:
-	  ->cell: %v
-	  cell->: %v
-	Properties:
-	  %v: interface name
-	  %v: multicast address, e.g "239.1.1.1:1000"
-`
-	fmt.Printf(format,
-		mcastReaderInputFormats,
-		mcastReaderOutputFormats,
-		config_mcastreader_interface,
-		config_mcastreader_address,
-	)
-}
-
-func McastReaderHelpShort() {
-	fmt.Printf("%v : write content to file\n", McastReaderName)
-}
-
 type mcastReader struct {
 	icell.Cell
 
 	intfName string
 	address  string
+	rtp      *rtpReceiver
 }
 
-func (c *mcastReader) Run() {
-	c.OnCellStart()
+func (c *mcastReader) Check() error {
+	if _, err := net.InterfaceByName(c.intfName); err != nil {
+		return fmt.Errorf("interface %v: %w", c.intfName, err)
+	}
+	addr, err := net.ResolveUDPAddr("udp", c.address)
+	if err != nil {
+		return err
+	}
+	if !addr.IP.IsMulticast() {
+		return fmt.Errorf("%v is not a multicast address", c.address)
+	}
+	return nil
+}
+
+func (c *mcastReader) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
 	defer c.OnCellFinished()
 
 	intf, err := net.InterfaceByName(c.intfName)
 	if err != nil {
-		fmt.Println("[mcast_reader]", err)
-		return
+		return err
 	}
 
 	addr, err := net.ResolveUDPAddr("udp", c.address)
 	if err != nil {
-		fmt.Println("[mcast_reader]", err)
-		return
+		return err
 	}
 
 	conn, err := net.ListenMulticastUDP("udp", intf, addr)
 
 	if err != nil {
-		fmt.Println("[mcast_reader] Error connecting:", err)
-		return
+		return fmt.Errorf("error connecting: %w", err)
 	}
 
 	defer conn.Close()
+	defer c.rtp.showResult()
 
-	for c.Running() {
-		// read network stream
-		buffer := make([]byte, 1316)
-		err := conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
-		if err != nil {
-			fmt.Println("[mcast_reader] Error setting read deadline:", err)
-			return
-		}
-		n, _, err := conn.ReadFrom(buffer)
-		if err != nil {
-			if errors.Is(err, os.ErrDeadlineExceeded) {
-				continue
-			}
-			fmt.Println("[mcast_reader] Error reading:", err)
-			return
-		}
-		data := buffer[:n]
-		c.PutOutput(icell.NewCellUnit(data, icell.BYTE_SLICE))
-	}
+	return readDatagrams(&c.Cell, conn, c.rtp)
 }
@@ -0,0 +1,34 @@
+package reader
+
+import (
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/tsutil/hls"
+	"github.com/potterxu/tsanalyzer/tsutil/rtp"
+)
+
+/* The origins are the data specific to a reader, set in icell.Metadata.Origin of the units it sends
+ * and kept by the converters, e.g. m.Origin.(*DatagramOrigin).Sender
+ */
+
+// DatagramOrigin is the datagram the data was received in, by udp_reader, mcast_reader and pcap_reader
+type DatagramOrigin struct {
+	Sender string      // address of the sender
+	Rtp    *rtp.Header // nil if not rtp
+}
+
+// StreamOrigin is the connection the data was received on, by http_reader and tcp_reader
+type StreamOrigin struct {
+	Remote string // address of the peer
+}
+
+// SegmentOrigin is the hls segment the data was downloaded in, by hls_reader
+type SegmentOrigin struct {
+	Remote  string       // address of the server, empty for local files
+	Segment *hls.Segment // tells the media sequence number and whether a discontinuity precedes it
+	Start   int64        // offset of the first byte of the segment in the source
+}
+
+// IsStart returns whether the data described by m starts the segment
+func (o *SegmentOrigin) IsStart(m *icell.Metadata) bool {
+	return m != nil && m.Offset == o.Start
+}
@@ -0,0 +1,216 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+	tserrinfo "github.com/potterxu/tsanalyzer/tsutil/errinfo"
+	"github.com/potterxu/tsanalyzer/tsutil/pcap"
+)
+
+const (
+	PcapReaderName string = "pcap_reader"
+
+	config_pcapreader_name   string = "name"
+	config_pcapreader_filter string = "filter"
+)
+
+var (
+	pcapReaderOutputFormats []icell.Format = []icell.Format{icell.BYTE_SLICE}
+
+	PcapReaderSpec = icell.Spec{
+		Description: "read udp payload from a pcap or pcapng capture, RTP/MP2T or raw",
+		Outputs:     []icell.PortSpec{{Name: icell.OUTPUT_PORT, Formats: pcapReaderOutputFormats}},
+		Schema: icell.Schema{
+			{Name: config_pcapreader_name, Type: icell.PROP_STRING, Required: true, Description: "capture file to read from"},
+			{Name: config_pcapreader_filter, Type: icell.PROP_STRING, Description: "destination of the datagrams, \"239.1.1.1:5000\", \"239.1.1.1\" or \":5000\", all udp datagrams if not provided"},
+			rtpProperty,
+		},
+	}
+)
+
+// pcapFilter matches the destination of the datagrams, the zero address or port matches any
+type pcapFilter struct {
+	addr netip.Addr
+	port uint16
+}
+
+func parsePcapFilter(s string) (pcapFilter, error) {
+	if s == "" {
+		return pcapFilter{}, nil
+	}
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return pcapFilter{addr: addrPort.Addr(), port: addrPort.Port()}, nil
+	}
+	if port, found := strings.CutPrefix(s, ":"); found {
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return pcapFilter{}, fmt.Errorf("%w for %v: invalid port in filter %v", errinfo.ErrInvalidCellConfig, PcapReaderName, s)
+		}
+		return pcapFilter{port: uint16(p)}, nil
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return pcapFilter{}, fmt.Errorf("%w for %v: invalid filter %v", errinfo.ErrInvalidCellConfig, PcapReaderName, s)
+	}
+	return pcapFilter{addr: addr}, nil
+}
+
+func (f pcapFilter) match(dst netip.AddrPort) bool {
+	return (!f.addr.IsValid() || f.addr == dst.Addr().Unmap()) && (f.port == 0 || f.port == dst.Port())
+}
+
+type pcapReader struct {
+	icell.Cell
+
+	filename string
+	filter   pcapFilter
+	rtp      *rtpReceiver
+
+	frames       uint64
+	filtered     uint64 // udp datagrams to other destinations
+	notUdp       uint64
+	invalid      uint64
+	truncated    uint64 // frames cut by the snapshot length of the capture
+	unsupported  uint64
+	destinations map[netip.AddrPort]uint64 // datagrams by destination, to tell a filter is needed
+}
+
+func NewPcapReader(config icell.Config) (icell.ICell, error) {
+	c := &pcapReader{
+		destinations: make(map[netip.AddrPort]uint64),
+	}
+	c.ICell = c
+	c.Init(config)
+	c.InitPorts(&PcapReaderSpec)
+
+	props, err := icell.ParseConfig(PcapReaderName, PcapReaderSpec.Schema, config)
+	if err != nil {
+		return nil, err
+	}
+	c.filename = props.String(config_pcapreader_name)
+	if c.filter, err = parsePcapFilter(props.String(config_pcapreader_filter)); err != nil {
+		return nil, err
+	}
+	c.rtp = newRtpReceiver(PcapReaderName, props.String(config_rtp))
+	return c, nil
+}
+
+func (c *pcapReader) Check() error {
+	return icell.CheckReadable(c.filename)
+}
+
+/* Run sends the udp payload of the datagrams in the capture,
+ * the metadata carries the capture time as receive time, the monotonic time since the first frame and the sender
+ */
+func (c *pcapReader) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+
+	file, err := os.Open(c.filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r, err := pcap.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%v: %w", c.filename, err)
+	}
+	defer c.showResult()
+	defer c.rtp.showResult()
+
+	var first time.Time
+	received := int64(0)
+	for c.Running() {
+		frame, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// a capture stopped while writing, the partial record is dropped
+			fmt.Printf("[%v] warning: %v frame %v: %v\n", PcapReaderName, c.filename, c.frames, err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%v frame %v: %w", c.filename, c.frames, err)
+		}
+		index := c.frames
+		c.frames++
+		datagram, err := pcap.DecodeUdp(frame.LinkType, frame.Data)
+		if err != nil {
+			if errors.Is(err, tserrinfo.ErrUnsupportedFrame) {
+				// ip fragments or link types repeat, reported in the result
+				c.unsupported++
+				continue
+			}
+			if frame.Length > len(frame.Data) {
+				// repeats for every large frame, reported in the result
+				c.truncated++
+				continue
+			}
+			c.invalid++
+			fmt.Printf("[%v] frame %v: %v\n", PcapReaderName, index, err)
+			continue
+		}
+		if datagram == nil {
+			c.notUdp++
+			continue
+		}
+		if !c.filter.match(datagram.Dst) {
+			c.filtered++
+			continue
+		}
+		c.destinations[datagram.Dst]++
+
+		metadata := icell.NewMetadata(c.Id())
+		if !frame.Timestamp.IsZero() {
+			if first.IsZero() {
+				first = frame.Timestamp
+			}
+			metadata.Received = frame.Timestamp
+			metadata.Monotonic = frame.Timestamp.Sub(first)
+		}
+		payload, header, ok := c.rtp.receive(datagram.Payload)
+		if !ok {
+			continue
+		}
+		metadata.Origin = &DatagramOrigin{Sender: datagram.Src.String(), Rtp: header}
+		metadata.Offset = received
+		received += int64(len(payload))
+		// the frame buffer is reused for the next frame
+		data := make([]byte, len(payload))
+		copy(data, payload)
+		c.PutOutput(icell.NewCellUnitWithMetadata(data, icell.BYTE_SLICE, metadata))
+	}
+	return nil
+}
+
+func (c *pcapReader) showResult() {
+	fmt.Printf("[%v] frames: %v, not udp: %v, filtered: %v, invalid: %v, truncated: %v, unsupported: %v\n",
+		PcapReaderName, c.frames, c.notUdp, c.filtered, c.invalid, c.truncated, c.unsupported)
+	if c.truncated > 0 {
+		fmt.Printf("[%v] %v frames truncated by the snapshot length, capture with a larger snaplen\n", PcapReaderName, c.truncated)
+	}
+	if len(c.destinations) > 1 {
+		// the payloads of the streams are mixed
+		destinations := make([]netip.AddrPort, 0, len(c.destinations))
+		for dst := range c.destinations {
+			destinations = append(destinations, dst)
+		}
+		slices.SortFunc(destinations, netip.AddrPort.Compare)
+		for _, dst := range destinations {
+			fmt.Printf("[%v] destination %v: %v datagrams\n", PcapReaderName, dst, c.destinations[dst])
+		}
+		fmt.Printf("[%v] several destinations, select one with filter\n", PcapReaderName)
+	}
+}
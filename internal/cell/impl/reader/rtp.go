@@ -0,0 +1,103 @@
+package reader
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/tsutil/rtp"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
+)
+
+const (
+	config_rtp string = "rtp"
+
+	RTP_AUTO string = "auto"
+	RTP_ON   string = "on"
+	RTP_OFF  string = "off"
+
+	// large enough for any udp datagram
+	datagram_size int = 1 << 16
+)
+
+// rtpProperty is the rtp mode of the network readers
+var rtpProperty = icell.Property{
+	Name:        config_rtp,
+	Type:        icell.PROP_STRING,
+	Default:     RTP_AUTO,
+	Enum:        []string{RTP_AUTO, RTP_ON, RTP_OFF},
+	Description: "strip the rtp headers of RTP/MP2T datagrams, auto detects rtp per datagram by the ts sync byte",
+}
+
+/* rtpReceiver strips the rtp headers of the datagrams received by a network reader
+ * and follows the sequence numbers of the rtp stream
+ */
+type rtpReceiver struct {
+	name string // of the reader
+	mode string
+
+	sequence *rtp.SequenceTracker
+	ssrc     uint32
+
+	datagrams uint64
+	rtp       uint64 // rtp datagrams
+	invalid   uint64 // datagrams dropped, not rtp in on mode
+}
+
+func newRtpReceiver(name string, mode string) *rtpReceiver {
+	return &rtpReceiver{
+		name:     name,
+		mode:     mode,
+		sequence: rtp.NewSequenceTracker(),
+	}
+}
+
+/* receive returns the ts payload of the datagram and its rtp header, nil if not rtp
+ * return false if the datagram is dropped, not rtp in on mode, duplicated or arrived after a later one
+ */
+func (r *rtpReceiver) receive(datagram []byte) ([]byte, *rtp.Header, bool) {
+	r.datagrams++
+	if r.mode == RTP_OFF || (r.mode == RTP_AUTO && len(datagram) > 0 && datagram[0] == ts.SYNC_BYTE) {
+		return datagram, nil, true
+	}
+	header, payload, err := rtp.Parse(datagram)
+	if err != nil {
+		if r.mode == RTP_AUTO {
+			return datagram, nil, true
+		}
+		r.invalid++
+		return nil, nil, false
+	}
+
+	r.rtp++
+	// the datagram buffer is reused
+	header.Extension = bytes.Clone(header.Extension)
+	if r.rtp > 1 && header.SSRC != r.ssrc {
+		fmt.Printf("[%v] RTP SSRC changed from %08x to %08x\n", r.name, r.ssrc, header.SSRC)
+		r.sequence.Reset()
+	}
+	r.ssrc = header.SSRC
+	switch status, lost := r.sequence.Update(header.Sequence); status {
+	case rtp.SEQUENCE_LOSS:
+		fmt.Printf("[%v] RTP loss of %v datagrams before sequence %v\n", r.name, lost, header.Sequence)
+	case rtp.SEQUENCE_DUPLICATE:
+		// the payload was already sent
+		return nil, nil, false
+	case rtp.SEQUENCE_REORDERED:
+		// the payload of the later datagrams was sent, it would break the order of the ts packets
+		return nil, nil, false
+	case rtp.SEQUENCE_RESTART:
+		fmt.Printf("[%v] RTP sequence restarted at %v\n", r.name, header.Sequence)
+	}
+	return payload, header, true
+}
+
+func (r *rtpReceiver) showResult() {
+	fmt.Printf("[%v] datagrams: %v, invalid datagrams: %v\n", r.name, r.datagrams, r.invalid)
+	if r.rtp == 0 {
+		return
+	}
+	stats := r.sequence.Stats()
+	fmt.Printf("[%v] rtp datagrams: %v, lost: %v, duplicated: %v, reordered: %v, restarts: %v\n",
+		r.name, r.rtp, stats.Lost, stats.Duplicated, stats.Reordered, stats.Restarts)
+}
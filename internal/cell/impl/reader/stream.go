@@ -0,0 +1,186 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+const (
+	config_stream_timeout    string = "timeout"
+	config_stream_retries    string = "retries"
+	config_stream_retrydelay string = "retry_delay"
+)
+
+// streamSchema are the properties of the readers of byte streams over a connection
+var streamSchema = icell.Schema{
+	{Name: config_stream_timeout, Type: icell.PROP_STRING, Default: "5s", Description: "timeout to connect and between received data"},
+	{Name: config_stream_retries, Type: icell.PROP_INT, Default: "0", Description: "reconnect attempts after the connection failed, -1 to reconnect forever"},
+	{Name: config_stream_retrydelay, Type: icell.PROP_STRING, Default: "1s", Description: "delay before reconnecting"},
+}
+
+// streamSchemaWith returns the stream properties with those of the same name replaced by overrides
+func streamSchemaWith(overrides ...icell.Property) icell.Schema {
+	s := slices.Clone(streamSchema)
+	for _, o := range overrides {
+		for i := range s {
+			if s[i].Name == o.Name {
+				s[i] = o
+			}
+		}
+	}
+	return s
+}
+
+var (
+	// buffers are returned to the pool when released by every consumer
+	streamBuffers = sync.Pool{
+		New: func() any {
+			buffer := make([]byte, chunk_size)
+			return &buffer
+		},
+	}
+
+	errStreamTimeout = errors.New("no data received")
+	errStreamEmpty   = errors.New("connection closed without data")
+)
+
+// stream is a connection opened by a stream reader
+type stream struct {
+	io.ReadCloser
+	remote string // address of the peer
+	origin any    // origin of the data read, a *StreamOrigin of remote if nil
+	eof    bool   // the stream was read to its end
+}
+
+/* streamReader reads a byte stream from the connections opened by open until the stream ends,
+ * it reconnects when the connection fails until the retries are exhausted,
+ * the retries are reset when data is received
+ */
+type streamReader struct {
+	name    string // of the reader
+	timeout time.Duration
+	retries int
+	delay   time.Duration
+
+	bytes       uint64
+	connections uint64
+	failures    uint64
+}
+
+func newStreamReader(name string, props icell.Properties) (*streamReader, error) {
+	r := &streamReader{
+		name:    name,
+		retries: props.Int(config_stream_retries),
+	}
+	var err error
+	if r.timeout, err = time.ParseDuration(props.String(config_stream_timeout)); err != nil || r.timeout <= 0 {
+		return nil, fmt.Errorf("%w for %v: invalid timeout %v", errinfo.ErrInvalidCellConfig, name, props.String(config_stream_timeout))
+	}
+	if r.delay, err = time.ParseDuration(props.String(config_stream_retrydelay)); err != nil || r.delay < 0 {
+		return nil, fmt.Errorf("%w for %v: invalid retry_delay %v", errinfo.ErrInvalidCellConfig, name, props.String(config_stream_retrydelay))
+	}
+	return r, nil
+}
+
+// run sends the data of the streams opened by open until the retries are exhausted or the cell is stopped
+func (r *streamReader) run(c *icell.Cell, open func(ctx context.Context) (*stream, error)) error {
+	defer r.showResult()
+
+	ctx := c.Context()
+	attempts := 0
+	for c.Running() {
+		s, err := open(ctx)
+		received := false
+		if err == nil {
+			r.connections++
+			received, err = r.read(c, s)
+		}
+		if !c.Running() || ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			// the end of the stream
+			return nil
+		}
+		if received {
+			attempts = 0
+		}
+		r.failures++
+		fmt.Printf("[%v] error: %v\n", r.name, err)
+		if r.retries >= 0 && attempts >= r.retries {
+			return err
+		}
+		attempts++
+		fmt.Printf("[%v] reconnect in %v, attempt %v\n", r.name, r.delay, attempts)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.delay):
+		}
+	}
+	return nil
+}
+
+// read sends the data of the stream until it ends, return whether any data was received
+func (r *streamReader) read(c *icell.Cell, s *stream) (bool, error) {
+	defer s.Close()
+	// unblock reading when idle for too long or aborted,
+	// the timer only runs while reading so a blocked output is not taken as idle
+	var timeout atomic.Bool
+	timer := time.AfterFunc(r.timeout, func() {
+		timeout.Store(true)
+		s.Close()
+	})
+	defer timer.Stop()
+	stopClose := context.AfterFunc(c.Context(), func() { s.Close() })
+	defer stopClose()
+
+	if s.origin == nil {
+		s.origin = &StreamOrigin{Remote: s.remote}
+	}
+	received := false
+	for c.Running() {
+		buffer := streamBuffers.Get().(*[]byte)
+		free := func() { streamBuffers.Put(buffer) }
+		timer.Reset(r.timeout)
+		cnt, err := s.Read(*buffer)
+		timer.Stop()
+		if cnt > 0 {
+			received = true
+			metadata := icell.NewMetadata(c.Id())
+			metadata.Offset = int64(r.bytes)
+			metadata.Origin = s.origin
+			r.bytes += uint64(cnt)
+			c.PutOutput(icell.NewPooledCellUnit((*buffer)[:cnt], icell.BYTE_SLICE, metadata, free))
+		} else {
+			free()
+		}
+		if err == io.EOF && !received {
+			return received, errStreamEmpty
+		}
+		if err == io.EOF {
+			s.eof = true
+			return received, nil
+		}
+		if err != nil {
+			if timeout.Load() {
+				return received, fmt.Errorf("%w for %v", errStreamTimeout, r.timeout)
+			}
+			return received, err
+		}
+	}
+	return received, nil
+}
+
+func (r *streamReader) showResult() {
+	fmt.Printf("[%v] bytes: %v, connections: %v, failures: %v\n", r.name, r.bytes, r.connections, r.failures)
+}
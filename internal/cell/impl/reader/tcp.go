@@ -0,0 +1,71 @@
+package reader
+
+import (
+	"context"
+	"net"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+)
+
+const (
+	TcpReaderName string = "tcp_reader"
+
+	config_tcpreader_address string = "addr"
+)
+
+var (
+	tcpReaderOutputFormats []icell.Format = []icell.Format{icell.BYTE_SLICE}
+
+	TcpReaderSpec = icell.Spec{
+		Description: "read a stream from a tcp server",
+		Outputs:     []icell.PortSpec{{Name: icell.OUTPUT_PORT, Formats: tcpReaderOutputFormats}},
+		Schema: append(icell.Schema{
+			{Name: config_tcpreader_address, Type: icell.PROP_STRING, Required: true, Description: "address to connect to, e.g. \"10.0.0.1:5000\""},
+		}, streamSchema...),
+	}
+)
+
+type tcpReader struct {
+	icell.Cell
+
+	address string
+	stream  *streamReader
+}
+
+func NewTcpReader(config icell.Config) (icell.ICell, error) {
+	c := &tcpReader{}
+	c.ICell = c
+	c.Init(config)
+	c.InitPorts(&TcpReaderSpec)
+
+	props, err := icell.ParseConfig(TcpReaderName, TcpReaderSpec.Schema, config)
+	if err != nil {
+		return nil, err
+	}
+	c.address = props.String(config_tcpreader_address)
+	if c.stream, err = newStreamReader(TcpReaderName, props); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *tcpReader) Check() error {
+	_, err := net.ResolveTCPAddr("tcp", c.address)
+	return err
+}
+
+func (c *tcpReader) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+
+	return c.stream.run(&c.Cell, c.open)
+}
+
+func (c *tcpReader) open(ctx context.Context) (*stream, error) {
+	dialer := net.Dialer{Timeout: c.stream.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, err
+	}
+	return &stream{ReadCloser: conn, remote: conn.RemoteAddr().String()}, nil
+}
@@ -0,0 +1,206 @@
+package reader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	UdpReaderName string = "udp_reader"
+
+	config_udpreader_address   string = "addr"
+	config_udpreader_interface string = "intf"
+	config_udpreader_sources   string = "sources"
+	config_udpreader_rcvbuf    string = "rcvbuf"
+
+	read_timeout = 100 * time.Millisecond
+)
+
+var (
+	udpReaderOutputFormats []icell.Format = []icell.Format{icell.BYTE_SLICE}
+
+	UdpReaderSpec = icell.Spec{
+		Description: "read udp payload from unicast, multicast or source-specific multicast, RTP/MP2T or raw",
+		Outputs:     []icell.PortSpec{{Name: icell.OUTPUT_PORT, Formats: udpReaderOutputFormats}},
+		Schema: icell.Schema{
+			{Name: config_udpreader_address, Type: icell.PROP_STRING, Required: true, Description: "address to receive from, unicast \":5000\" or multicast group \"232.1.1.1:5000\", ipv6 in brackets, e.g. \"[ff3e::1]:5000\""},
+			{Name: config_udpreader_interface, Type: icell.PROP_STRING, Description: "interface name to join the multicast group on, chosen by the system if not provided"},
+			{Name: config_udpreader_sources, Type: icell.PROP_STRING, Description: "sources of a source-specific multicast join (IGMPv3 or MLDv2), split by \",\", of the family of addr, any source if not provided"},
+			{Name: config_udpreader_rcvbuf, Type: icell.PROP_UINT, Default: "0", Description: "socket receive buffer size in bytes, 0 for the system default"},
+			rtpProperty,
+		},
+	}
+)
+
+type udpReader struct {
+	icell.Cell
+
+	address  string
+	intfName string
+	sources  []string
+	rcvbuf   uint64
+	rtp      *rtpReceiver
+}
+
+func NewUdpReader(config icell.Config) (icell.ICell, error) {
+	c := &udpReader{}
+	c.ICell = c
+	c.Init(config)
+	c.InitPorts(&UdpReaderSpec)
+
+	props, err := icell.ParseConfig(UdpReaderName, UdpReaderSpec.Schema, config)
+	if err != nil {
+		return nil, err
+	}
+	c.address = props.String(config_udpreader_address)
+	c.intfName = props.String(config_udpreader_interface)
+	if sources := props.String(config_udpreader_sources); sources != "" {
+		c.sources = strings.Split(sources, ",")
+	}
+	c.rcvbuf = props.Uint(config_udpreader_rcvbuf)
+	c.rtp = newRtpReceiver(UdpReaderName, props.String(config_rtp))
+	return c, nil
+}
+
+func (c *udpReader) Check() error {
+	_, _, _, err := c.resolve()
+	return err
+}
+
+// groupJoiner joins multicast groups, implemented by ipv4.PacketConn and ipv6.PacketConn
+type groupJoiner interface {
+	JoinGroup(ifi *net.Interface, group net.Addr) error
+	JoinSourceSpecificGroup(ifi *net.Interface, group, source net.Addr) error
+}
+
+// udpNetwork returns "udp6" for an ipv6 address, "udp4" otherwise, including an address without host
+func udpNetwork(addr *net.UDPAddr) string {
+	if addr.IP != nil && addr.IP.To4() == nil {
+		return "udp6"
+	}
+	return "udp4"
+}
+
+// resolve returns the address to bind, the interface and the sources to join
+func (c *udpReader) resolve() (*net.UDPAddr, *net.Interface, []net.Addr, error) {
+	addr, err := net.ResolveUDPAddr("udp", c.address)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	is6 := udpNetwork(addr) == "udp6"
+	var intf *net.Interface
+	if c.intfName != "" {
+		if intf, err = net.InterfaceByName(c.intfName); err != nil {
+			return nil, nil, nil, fmt.Errorf("interface %v: %w", c.intfName, err)
+		}
+	}
+	sources := make([]net.Addr, 0, len(c.sources))
+	for _, s := range c.sources {
+		ip := net.ParseIP(strings.TrimSpace(s))
+		if ip == nil || ip.IsMulticast() || (ip.To4() == nil) != is6 {
+			return nil, nil, nil, fmt.Errorf("%v is not an unicast source of the family of %v", s, c.address)
+		}
+		sources = append(sources, &net.UDPAddr{IP: ip})
+	}
+	if len(sources) > 0 && !addr.IP.IsMulticast() {
+		return nil, nil, nil, fmt.Errorf("sources require a multicast address, not %v", c.address)
+	}
+	return addr, intf, sources, nil
+}
+
+func (c *udpReader) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+
+	addr, intf, sources, err := c.resolve()
+	if err != nil {
+		return err
+	}
+	conn, err := c.listen(addr, intf, sources)
+	if err != nil {
+		return fmt.Errorf("error connecting: %w", err)
+	}
+	defer conn.Close()
+	defer c.rtp.showResult()
+
+	return readDatagrams(&c.Cell, conn, c.rtp)
+}
+
+// listen binds the address and joins the multicast group, from the sources if any
+func (c *udpReader) listen(addr *net.UDPAddr, intf *net.Interface, sources []net.Addr) (*net.UDPConn, error) {
+	// bound to the group if multicast, the datagrams of other groups on the port are not received
+	conn, err := net.ListenUDP(udpNetwork(addr), addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.rcvbuf > 0 {
+		if err := conn.SetReadBuffer(int(c.rcvbuf)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("error setting receive buffer: %w", err)
+		}
+	}
+	if !addr.IP.IsMulticast() {
+		return conn, nil
+	}
+
+	var p groupJoiner = ipv4.NewPacketConn(conn)
+	if udpNetwork(addr) == "udp6" {
+		p = ipv6.NewPacketConn(conn)
+	}
+	group := &net.UDPAddr{IP: addr.IP}
+	if len(sources) == 0 {
+		err = p.JoinGroup(intf, group)
+	}
+	for _, source := range sources {
+		if err = p.JoinSourceSpecificGroup(intf, group, source); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error joining %v: %w", c.address, err)
+	}
+	return conn, nil
+}
+
+/* readDatagrams sends the payload of the datagrams received on conn until the cell is stopped
+ * the metadata carries the sender, the rtp header and the offset of the payload in the stream
+ */
+func readDatagrams(c *icell.Cell, conn net.PacketConn, receiver *rtpReceiver) error {
+	received := int64(0)
+	buffer := make([]byte, datagram_size)
+	for c.Running() {
+		if err := conn.SetReadDeadline(time.Now().Add(read_timeout)); err != nil {
+			return fmt.Errorf("error setting read deadline: %w", err)
+		}
+		n, sender, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				continue
+			}
+			return fmt.Errorf("error reading: %w", err)
+		}
+		metadata := icell.NewMetadata(c.Id())
+		payload, header, ok := receiver.receive(buffer[:n])
+		if !ok {
+			continue
+		}
+		metadata.Origin = &DatagramOrigin{Sender: sender.String(), Rtp: header}
+		metadata.Offset = received
+		received += int64(len(payload))
+		// the buffer is reused for the next datagram
+		data := make([]byte, len(payload))
+		copy(data, payload)
+		c.PutOutput(icell.NewCellUnitWithMetadata(data, icell.BYTE_SLICE, metadata))
+	}
+	return nil
+}
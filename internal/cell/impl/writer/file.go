@@ -2,78 +2,84 @@ package writer
 
 import (
 	"bufio"
+	"context"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"time"
 
 	"github.com/Comcast/gots/v2/packet"
 	"github.com/potterxu/tsanalyzer/internal/cell/icell"
 	"github.com/potterxu/tsanalyzer/internal/errinfo"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
 )
 
 const (
 	FileWriterName string = "file_writer"
 
-	config_filewriter_name string = "name"
+	config_filewriter_name       string = "name"
+	config_filewriter_packetsize string = "packet_size"
 )
 
 var (
-	fileWriterInputFormats  []icell.Format = []icell.Format{icell.BYTE_SLICE, icell.STRING, icell.TS_PACKET}
-	fileWriterOutputFormats []icell.Format = nil
+	fileWriterInputFormats []icell.Format = []icell.Format{icell.BYTE_SLICE, icell.STRING, icell.TS_PACKET, icell.TS_PACKETS, icell.PES_PACKET, icell.SECTION}
+
+	FileWriterSpec = icell.Spec{
+		Description: "write content to file, the payload of pes packets as elementary stream",
+		Inputs:      []icell.PortSpec{{Name: icell.INPUT_PORT, Formats: fileWriterInputFormats}},
+		Schema: icell.Schema{
+			{Name: config_filewriter_name, Type: icell.PROP_STRING, Required: true, Description: "filename to write to"},
+			{Name: config_filewriter_packetsize, Type: icell.PROP_INT, Default: "188", Enum: []string{"188", "192", "204"}, Description: "size of the ts packets written, 192 adds the M2TS header and 204 the parity bytes"},
+		},
+	}
 )
 
 type FileWriter struct {
 	icell.Cell
 
-	filename string
-}
-
-func FileWriterHelp() {
-	FileWriterHelpShort()
-	format := `	IO:
-	  ->cell: %v
-	  cell->: %v
-	Properties:
-	  %v: filename to write to
-`
-	fmt.Printf(format,
-		fileWriterInputFormats,
-		fileWriterOutputFormats,
-		config_filewriter_name)
-}
+	filename   string
+	packetSize int
 
-func FileWriterHelpShort() {
-	fmt.Printf("%v : write content to file\n", FileWriterName)
+	previous time.Duration // receive time of the last unit of packets, on the clock of the source
 }
 
-func NewFileWriter(stopChan chan bool, config icell.Config) (icell.ICell, error) {
+func NewFileWriter(config icell.Config) (icell.ICell, error) {
 	c := &FileWriter{}
 	c.ICell = c
-	c.Init(stopChan, config)
-
-	if filename, ok := config[config_filewriter_name]; ok {
-		c.filename = filename
-	} else {
-		fmt.Println("file name not provided for FileWriter")
-		FileWriterHelp()
-		return nil, errinfo.ErrInvalidCellConfig
+	c.Init(config)
+	c.InitPorts(&FileWriterSpec)
+
+	props, err := icell.ParseConfig(FileWriterName, FileWriterSpec.Schema, config)
+	if err != nil {
+		return nil, err
 	}
+	c.filename = props.String(config_filewriter_name)
+	c.packetSize = props.Int(config_filewriter_packetsize)
 	return c, nil
 }
 
-func (c *FileWriter) Run() {
-	c.OnCellStart()
+func (c *FileWriter) Check() error {
+	return icell.CheckWritable(c.filename)
+}
+
+func (c *FileWriter) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
 	defer c.OnCellFinished()
 
+	// the missing directories are created, as verified by Check
+	if err := os.MkdirAll(filepath.Dir(c.filename), 0755); err != nil {
+		return err
+	}
 	file, err := os.Create(c.filename)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer file.Close()
 
 	writer := bufio.NewWriter(file)
-	defer writer.Flush()
 	for {
 		unit, ok := c.GetInput()
 		if !ok {
@@ -83,27 +89,96 @@ func (c *FileWriter) Run() {
 		switch reflect.TypeOf(unit.Data()) {
 		case icell.FormatToType[icell.BYTE_SLICE]:
 			err = writeBytes(writer, unit.Data().([]byte))
+			icell.Release(unit)
 		case icell.FormatToType[icell.STRING]:
 			err = writeBytes(writer, []byte(unit.Data().(string)))
 		case icell.FormatToType[icell.TS_PACKET]:
 			data := unit.Data().(packet.Packet)
-			bytes := []byte(data[:])
-			err = writeBytes(writer, bytes)
+			err = c.writePacket(writer, &data, unit.Metadata(), 0, 1)
+		case icell.FormatToType[icell.TS_PACKETS]:
+			pkts := unit.Data().([]packet.Packet)
+			for i := range pkts {
+				if err = c.writePacket(writer, &pkts[i], unit.Metadata(), i, len(pkts)); err != nil {
+					break
+				}
+			}
+		case icell.FormatToType[icell.PES_PACKET]:
+			err = writeBytes(writer, unit.Data().(*ts.PesPacket).Payload)
+		case icell.FormatToType[icell.SECTION]:
+			err = writeBytes(writer, unit.Data().(*ts.Section).Data)
 		default:
-			fmt.Printf("Invalid input type %v for FileWriter", reflect.TypeOf(unit.Data()))
-			err = errinfo.ErrInvalidUnitFormat
+			err = fmt.Errorf("%w: %v", errinfo.ErrInvalidUnitFormat, reflect.TypeOf(unit.Data()))
 		}
 		if err != nil {
-			break
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+/* writePacket writes the nth of the cnt packets of the unit in the size of the writer
+ * 192: the M2TS header of the source if any, else an arrival time stamp from the receive time
+ * 204: the parity bytes of the source if any, else zeros
+ */
+func (c *FileWriter) writePacket(w io.Writer, pkt *packet.Packet, metadata *icell.Metadata, n int, cnt int) error {
+	var extra []byte
+	if metadata != nil && metadata.PacketSize == c.packetSize {
+		extra = metadata.ExtraAt(n)
+	}
+	switch c.packetSize {
+	case ts.M2TS_PACKET_SIZE:
+		if extra == nil {
+			extra = m2tsHeader(c.arrival(metadata, n, cnt))
+		}
+		if err := writeBytes(w, extra); err != nil {
+			return err
+		}
+		return writeBytes(w, pkt[:])
+	case ts.RS_PACKET_SIZE:
+		if extra == nil {
+			extra = rsParity[:]
 		}
+		if err := writeBytes(w, pkt[:]); err != nil {
+			return err
+		}
+		return writeBytes(w, extra)
+	default:
+		return writeBytes(w, pkt[:])
 	}
+}
 
+// rsParity stands for the parity bytes of 204-byte packets not received as such
+var rsParity [ts.RS_PACKET_SIZE - ts.TS_PACKET_SIZE]byte
+
+/* arrival returns the receive time of the nth of the cnt packets of the unit,
+ * the packets received at once are spread evenly since the previous unit, 0 if unknown
+ */
+func (c *FileWriter) arrival(metadata *icell.Metadata, n int, cnt int) time.Duration {
+	if metadata == nil {
+		return 0
+	}
+	received := metadata.Monotonic
+	at := received
+	if c.previous > 0 && c.previous < received {
+		at = c.previous + (received-c.previous)*time.Duration(n+1)/time.Duration(cnt)
+	}
+	if n == cnt-1 {
+		c.previous = received
+	}
+	return at
 }
 
-func writeBytes(w io.Writer, data []byte) error {
-	if _, err := w.Write(data); err != nil {
-		fmt.Println(err)
-		return err
+// m2tsHeader returns a header with the receive time in 27MHz as arrival time stamp
+func m2tsHeader(received time.Duration) []byte {
+	header := make([]byte, ts.M2TS_PACKET_SIZE-ts.TS_PACKET_SIZE)
+	if received > 0 {
+		ticks := uint64(received) * 27 / 1000
+		binary.BigEndian.PutUint32(header, uint32(ticks)&ts.M2TS_TIMESTAMP_MASK)
 	}
-	return nil
+	return header
+}
+
+func writeBytes(w io.Writer, data []byte) error {
+	_, err := w.Write(data)
+	return err
 }
@@ -1,6 +1,7 @@
 package cell
 
 import (
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
 	"github.com/potterxu/tsanalyzer/internal/cell/impl/converter"
 	"github.com/potterxu/tsanalyzer/internal/cell/impl/processor"
 	"github.com/potterxu/tsanalyzer/internal/cell/impl/reader"
@@ -9,14 +10,38 @@ import (
 
 // register the cell here
 func init() {
-	register(type_reader, reader.FileReaderName, reader.NewFileReader, reader.FileReaderHelpShort, reader.FileReaderHelp)
-	register(type_converter, converter.BytesConverterName, converter.NewBytesConverter, converter.BytesConverterHelpShort, converter.BytesConverterHelp)
-	register(type_writer, writer.FileWriterName, writer.NewFileWriter, writer.FileWriterHelpShort, writer.FileWriterHelp)
-	register(type_processor, processor.VbvName, processor.NewVbv, processor.VbvHelpShort, processor.VbvHelp)
-	register(type_reader, reader.McastReaderName, reader.NewMcastReader, reader.McastReaderHelpShort, reader.McastReaderHelp)
+	register(TYPE_READER, reader.FileReaderName, reader.NewFileReader, reader.FileReaderSpec)
+	register(TYPE_CONVERTER, converter.BytesConverterName, converter.NewBytesConverter, converter.BytesConverterSpec)
+	register(TYPE_WRITER, writer.FileWriterName, writer.NewFileWriter, writer.FileWriterSpec)
+	register(TYPE_PROCESSOR, processor.VbvName, processor.NewVbv, processor.VbvSpec)
+	register(TYPE_READER, reader.McastReaderName, reader.NewMcastReader, reader.McastReaderSpec)
+	register(TYPE_PROCESSOR, processor.CompareName, processor.NewCompare, processor.CompareSpec)
+	register(TYPE_CONVERTER, converter.PesConverterName, converter.NewPesConverter, converter.PesConverterSpec)
+	register(TYPE_CONVERTER, converter.SectionConverterName, converter.NewSectionConverter, converter.SectionConverterSpec)
+	register(TYPE_READER, reader.UdpReaderName, reader.NewUdpReader, reader.UdpReaderSpec)
+	register(TYPE_READER, reader.HttpReaderName, reader.NewHttpReader, reader.HttpReaderSpec)
+	register(TYPE_READER, reader.TcpReaderName, reader.NewTcpReader, reader.TcpReaderSpec)
+	register(TYPE_READER, reader.HlsReaderName, reader.NewHlsReader, reader.HlsReaderSpec)
+	register(TYPE_READER, reader.PcapReaderName, reader.NewPcapReader, reader.PcapReaderSpec)
+
+	// converters inserted automatically between incompatible cells
+	// the first matching converter is preferred, batches are cheaper to pass through edges
+	RegisterConverter(converter.BytesConverterName, icell.BYTE_SLICE, icell.TS_PACKETS, converter.BytesConverterConfig(icell.TS_PACKETS))
+	RegisterConverter(converter.BytesConverterName, icell.BYTE_SLICE, icell.TS_PACKET, converter.BytesConverterConfig(icell.TS_PACKET))
+	RegisterConverter(converter.PesConverterName, icell.TS_PACKETS, icell.PES_PACKET, converter.PesConverterConfig())
+	RegisterConverter(converter.PesConverterName, icell.TS_PACKET, icell.PES_PACKET, converter.PesConverterConfig())
+	RegisterConverter(converter.SectionConverterName, icell.TS_PACKETS, icell.SECTION, converter.SectionConverterConfig())
+	RegisterConverter(converter.SectionConverterName, icell.TS_PACKET, icell.SECTION, converter.SectionConverterConfig())
 }
 
-func register(t cellType, name string, ctor cell_ctor, short cell_short, help cell_help) {
-	cells[t] = append(cells[t], name)
-	factories[name] = &factory{ctor, short, help}
+func register(t CellType, name string, ctor Constructor, spec icell.Spec) {
+	err := Register(Registration{
+		Type: t,
+		Name: name,
+		Ctor: ctor,
+		Spec: spec,
+	})
+	if err != nil {
+		panic(err)
+	}
 }
@@ -1,12 +1,37 @@
 package errinfo
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrCellNotSupport      error = errors.New("cell not supported")
 	ErrFailedToBuildGraph  error = errors.New("failed to build graph")
 	ErrFailedToConnectCell error = errors.New("failed to connect cell")
+	ErrFormatMismatch      error = errors.New("format mismatch")
 	ErrInvalidCellConfig   error = errors.New("invalid cell config")
+	ErrInvalidEdgeOptions  error = errors.New("invalid edge options")
 	ErrInvalidMethod       error = errors.New("invalid method")
+	ErrInvalidPort         error = errors.New("invalid port")
+	ErrInvalidRegistration error = errors.New("invalid registration")
 	ErrInvalidUnitFormat   error = errors.New("invalid unit format")
 )
+
+// CellError is the error reported by a cell in the graph
+type CellError struct {
+	Cell string // name of the cell
+	Id   string // user defined id of the cell, empty if not named
+	Err  error
+}
+
+func (e *CellError) Error() string {
+	if e.Id == "" {
+		return fmt.Sprintf("%v: %v", e.Cell, e.Err)
+	}
+	return fmt.Sprintf("%v(%v): %v", e.Cell, e.Id, e.Err)
+}
+
+func (e *CellError) Unwrap() error {
+	return e.Err
+}
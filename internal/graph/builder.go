@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+// Builder builds a graph programmatically
+// cells are referred by the index returned when added
+type Builder struct {
+	g     *graph
+	built bool
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		g: &graph{
+			desc:  &graphDesc{},
+			cells: make([]icell.ICell, 0),
+			log:   os.Stdout,
+		},
+	}
+}
+
+// SetLog sets where the messages of building and running the graph are written, os.Stdout by default
+func (b *Builder) SetLog(w io.Writer) {
+	b.g.log = w
+}
+
+// Add creates a registered cell by name
+func (b *Builder) Add(name string, config icell.Config) (int, error) {
+	info := newCellInfo(name)
+	for k, v := range config {
+		info.addProperty(k, v)
+	}
+	return b.g.addCell(info)
+}
+
+// AddCell adds a constructed cell, name is used for reporting
+func (b *Builder) AddCell(name string, c icell.ICell) int {
+	return b.g.addICell(newCellInfo(name), c)
+}
+
+// Connect the output port of cells[src] to the input port of cells[dst],
+// the zero value of opts uses a blocking queue of icell.EDGE_BUFFER units
+func (b *Builder) Connect(src int, srcPort string, dst int, dstPort string, opts icell.EdgeOptions) error {
+	if src < 0 || src >= len(b.g.cells) || dst < 0 || dst >= len(b.g.cells) {
+		return fmt.Errorf("%w: cell index out of range", errinfo.ErrFailedToConnectCell)
+	}
+	b.g.desc.addLink(src, srcPort, dst, dstPort, opts)
+	return nil
+}
+
+// Build validates and connects the graph, the builder can not be used afterwards
+func (b *Builder) Build() (Graph, error) {
+	if b.built {
+		return nil, fmt.Errorf("%w: already built", errinfo.ErrFailedToBuildGraph)
+	}
+	b.built = true
+	if err := b.g.build(); err != nil {
+		return nil, err
+	}
+	return b.g, nil
+}
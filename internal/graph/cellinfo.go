@@ -1,10 +1,18 @@
 package graph
 
-import "github.com/potterxu/tsanalyzer/internal/cell/icell"
+import (
+	"fmt"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+)
 
 type cellInfo struct {
 	name   string
 	config icell.Config
+
+	// position in the definition file, line is 0 if not defined in a file
+	filename string
+	line     int
 }
 
 func newCellInfo(name string) *cellInfo {
@@ -17,3 +25,109 @@ func newCellInfo(name string) *cellInfo {
 func (ci *cellInfo) addProperty(key, value string) {
 	ci.config[key] = value
 }
+
+// id returns the user defined id of the cell, empty if not named
+func (ci *cellInfo) id() string {
+	return ci.config[icell.CONFIG_CELL_ID]
+}
+
+// position returns "filename:line" of the cell definition, empty if not defined in a file
+func (ci *cellInfo) position() string {
+	if ci.line == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v:%v", ci.filename, ci.line)
+}
+
+func (ci *cellInfo) String() string {
+	if id := ci.id(); id != "" {
+		return fmt.Sprintf("%v(%v)", ci.name, id)
+	}
+	return ci.name
+}
+
+// linkInfo describes a connection from cells[src].srcPort to cells[dst].dstPort
+type linkInfo struct {
+	src     int
+	srcPort string
+	dst     int
+	dstPort string
+	opts    icell.EdgeOptions
+}
+
+type graphDesc struct {
+	cells []*cellInfo
+	links []*linkInfo
+}
+
+func (gd *graphDesc) addCell(info *cellInfo) int {
+	gd.cells = append(gd.cells, info)
+	return len(gd.cells) - 1
+}
+
+func (gd *graphDesc) addLink(src int, srcPort string, dst int, dstPort string, opts icell.EdgeOptions) {
+	gd.links = append(gd.links, &linkInfo{
+		src:     src,
+		srcPort: srcPort,
+		dst:     dst,
+		dstPort: dstPort,
+		opts:    opts,
+	})
+}
+
+// findCell returns the index of the cell with the given id
+func (gd *graphDesc) findCell(id string) (int, bool) {
+	for i, info := range gd.cells {
+		if info.id() == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// hasInput reports whether any link ends at cells[index]
+func (gd *graphDesc) hasInput(index int) bool {
+	for _, link := range gd.links {
+		if link.dst == index {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLink reports whether cells[index] is connected to any other cell
+func (gd *graphDesc) hasLink(index int) bool {
+	for _, link := range gd.links {
+		if link.src == index || link.dst == index {
+			return true
+		}
+	}
+	return false
+}
+
+// sorted returns the cell indexes in topological order
+// return false if the graph contains a cycle
+func (gd *graphDesc) sorted() ([]int, bool) {
+	inDegree := make([]int, len(gd.cells))
+	for _, link := range gd.links {
+		inDegree[link.dst]++
+	}
+	order := make([]int, 0, len(gd.cells))
+	for i := range gd.cells {
+		if inDegree[i] == 0 {
+			order = append(order, i)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		for _, link := range gd.links {
+			if link.src != order[i] {
+				continue
+			}
+			inDegree[link.dst]--
+			if inDegree[link.dst] == 0 {
+				order = append(order, link.dst)
+			}
+		}
+	}
+	return order, len(order) == len(gd.cells)
+}
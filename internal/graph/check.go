@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/potterxu/tsanalyzer/internal/cell"
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+// CheckGraph validates the pipe syntax without processing any data, the inserted converters are reported to log
+// return every problem found, see checkGraph
+func CheckGraph(gDesc string, log io.Writer) error {
+	desc, ok := getGraphDesc(gDesc)
+	if !ok {
+		return errinfo.ErrFailedToBuildGraph
+	}
+	return checkGraph(desc, log)
+}
+
+// CheckGraphFile validates the pipeline definition file without processing any data, see CheckGraph
+func CheckGraphFile(filename string, log io.Writer) error {
+	desc, ok := getFileGraphDesc(filename)
+	if !ok {
+		return errinfo.ErrFailedToBuildGraph
+	}
+	return checkGraph(desc, log)
+}
+
+/* checkGraph constructs every cell and reports all problems at once:
+ * unknown or invalid properties, incompatible connections,
+ * and resources verified by cells implementing icell.Checker
+ */
+func checkGraph(desc *graphDesc, log io.Writer) error {
+	errs := make([]error, 0)
+	cellError := func(info *cellInfo, err error) error {
+		return &errinfo.CellError{Cell: info.name, Id: info.id(), Err: err}
+	}
+
+	g := &graph{
+		desc:  &graphDesc{},
+		cells: make([]icell.ICell, 0, len(desc.cells)),
+		log:   log,
+	}
+	// index of every described cell in g, -1 if it failed to be constructed
+	index := make([]int, len(desc.cells))
+	for i, info := range desc.cells {
+		if spec, ok := cell.GetSpec(info.name); ok {
+			for _, name := range icell.UnknownProperties(spec.Schema, info.config) {
+				errs = append(errs, cellError(info, fmt.Errorf("%w: unknown property %v", errinfo.ErrInvalidCellConfig, name)))
+			}
+		}
+		c, err := cell.NewCell(info.name, info.config)
+		if err != nil {
+			errs = append(errs, cellError(info, err))
+			index[i] = -1
+			continue
+		}
+		index[i] = g.addICell(info, c)
+	}
+
+	// connections of the valid cells
+	for _, link := range desc.links {
+		if index[link.src] >= 0 && index[link.dst] >= 0 {
+			g.desc.addLink(index[link.src], link.srcPort, index[link.dst], link.dstPort, link.opts)
+		}
+	}
+	if err := g.insertConverters(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, link := range g.desc.links {
+		src, dst := g.cells[link.src], g.cells[link.dst]
+		if err := src.Connect(link.srcPort, dst, link.dstPort, link.opts); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %v to %v: %w", errinfo.ErrFailedToConnectCell,
+				portString(g.desc.cells[link.src], link.srcPort),
+				portString(g.desc.cells[link.dst], link.dstPort),
+				err))
+		}
+	}
+
+	for i, c := range g.cells {
+		if checker, ok := c.(icell.Checker); ok {
+			if err := checker.Check(); err != nil {
+				errs = append(errs, cellError(g.desc.cells[i], err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
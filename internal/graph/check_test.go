@@ -0,0 +1,38 @@
+package graph
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+func TestCheckGraph(t *testing.T) {
+	dir := t.TempDir()
+	input := path.Join(dir, "in.ts")
+	if err := os.WriteFile(input, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the missing directories are created by file_writer
+	for _, output := range []string{path.Join(dir, "out.ts"), path.Join(dir, "missing", "dir", "out.ts")} {
+		if err := CheckGraph("file_reader name="+input+" ! file_writer name="+output, io.Discard); err != nil {
+			t.Errorf("expected check passed for %v, but get %v\n", output, err)
+		}
+	}
+
+	err := CheckGraph("file_reader name="+path.Join(dir, "missing.ts")+" sise=10 ! vbv pcr=9000 pids=256 "+
+		"file_reader name="+input+" ! compare id=cmp ! file_writer name="+path.Join(input, "out.ts"), io.Discard)
+	if err == nil {
+		t.Fatal("expected check failed")
+	}
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(errs) != 5 {
+		t.Errorf("expected 5 problems, but get %v:\n%v\n", len(errs), err)
+	}
+	if !errors.Is(err, errinfo.ErrInvalidCellConfig) || !errors.Is(err, errinfo.ErrFailedToConnectCell) || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected config, connection and file problems, but get %v\n", err)
+	}
+}
@@ -0,0 +1,155 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/potterxu/tsanalyzer/internal/cell"
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+)
+
+// Diagram formats supported by Export
+const (
+	EXPORT_DOT     = "dot"
+	EXPORT_MERMAID = "mermaid"
+)
+
+// exportNode is a cell of the resolved graph
+type exportNode struct {
+	id    string
+	lines []string // name(id), type and properties
+}
+
+// exportEdge is a connection of the resolved graph
+type exportEdge struct {
+	src   string
+	dst   string
+	lines []string // ports, negotiated formats and queue
+}
+
+// Export writes the resolved graph, including the inserted converters, as a diagram
+func (g *graph) Export(w io.Writer, format string) error {
+	nodes, edges := g.exportElements()
+	switch format {
+	case EXPORT_DOT:
+		writeDot(w, nodes, edges)
+	case EXPORT_MERMAID:
+		writeMermaid(w, nodes, edges)
+	default:
+		return fmt.Errorf("unknown diagram format %v", format)
+	}
+	return nil
+}
+
+func (g *graph) exportElements() ([]exportNode, []exportEdge) {
+	index := make(map[icell.ICell]int, len(g.cells))
+	for i, c := range g.cells {
+		index[c] = i
+	}
+	nodeId := func(i int) string {
+		return fmt.Sprintf("c%v", i)
+	}
+
+	nodes := make([]exportNode, 0, len(g.cells))
+	edges := make([]exportEdge, 0, len(g.desc.links))
+	order, _ := g.desc.sorted()
+	for _, i := range order {
+		info := g.desc.cells[i]
+		lines := []string{info.String()}
+		if t, ok := cell.GetType(info.name); ok {
+			lines = append(lines, t.String())
+		}
+		keys := make([]string, 0, len(info.config))
+		for k := range info.config {
+			if k != icell.CONFIG_CELL_ID {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			lines = append(lines, fmt.Sprintf("%v=%v", k, info.config[k]))
+		}
+		nodes = append(nodes, exportNode{id: nodeId(i), lines: lines})
+
+		for _, e := range g.cells[i].OutputEdges() {
+			dst, ok := index[e.Dst()]
+			if !ok {
+				continue
+			}
+			lines := make([]string, 0, 3)
+			if e.SrcPort() != icell.DEFAULT_PORT || e.DstPort() != icell.DEFAULT_PORT {
+				lines = append(lines, fmt.Sprintf("%v -> %v",
+					portName(e.SrcPort(), e.Src().OutputPorts()), portName(e.DstPort(), e.Dst().InputPorts())))
+			}
+			if len(e.Formats()) > 0 {
+				formats := make([]string, len(e.Formats()))
+				for j, f := range e.Formats() {
+					formats[j] = string(f)
+				}
+				lines = append(lines, strings.Join(formats, ", "))
+			}
+			if opts := e.Options().String(); opts != "" {
+				lines = append(lines, token_queue+" "+opts)
+			}
+			edges = append(edges, exportEdge{src: nodeId(i), dst: nodeId(dst), lines: lines})
+		}
+	}
+	return nodes, edges
+}
+
+// portName resolves DEFAULT_PORT to the first declared port
+func portName(port string, ports []string) string {
+	if port == icell.DEFAULT_PORT && len(ports) > 0 {
+		return ports[0]
+	}
+	return port
+}
+
+func writeDot(w io.Writer, nodes []exportNode, edges []exportEdge) {
+	escape := func(lines []string) string {
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		escaped := make([]string, len(lines))
+		for i, line := range lines {
+			escaped[i] = r.Replace(line)
+		}
+		return strings.Join(escaped, `\n`)
+	}
+	fmt.Fprintln(w, "digraph pipeline {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "  %v [label=\"%v\"];\n", n.id, escape(n.lines))
+	}
+	for _, e := range edges {
+		if len(e.lines) == 0 {
+			fmt.Fprintf(w, "  %v -> %v;\n", e.src, e.dst)
+			continue
+		}
+		fmt.Fprintf(w, "  %v -> %v [label=\"%v\"];\n", e.src, e.dst, escape(e.lines))
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func writeMermaid(w io.Writer, nodes []exportNode, edges []exportEdge) {
+	escape := func(lines []string) string {
+		r := strings.NewReplacer(`"`, "#quot;")
+		escaped := make([]string, len(lines))
+		for i, line := range lines {
+			escaped[i] = r.Replace(line)
+		}
+		return strings.Join(escaped, "<br/>")
+	}
+	fmt.Fprintln(w, "flowchart LR")
+	for _, n := range nodes {
+		fmt.Fprintf(w, "  %v[\"%v\"]\n", n.id, escape(n.lines))
+	}
+	for _, e := range edges {
+		if len(e.lines) == 0 {
+			fmt.Fprintf(w, "  %v --> %v\n", e.src, e.dst)
+			continue
+		}
+		fmt.Fprintf(w, "  %v -- \"%v\" --> %v\n", e.src, escape(e.lines), e.dst)
+	}
+}
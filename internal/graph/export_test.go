@@ -0,0 +1,44 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	var log strings.Builder
+	g, err := NewGraph("file_reader name=in.ts id=src ! queue capacity=100 ! compare id=cmp src. ! cmp.b", &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the build messages are kept out of the diagram
+	if !strings.Contains(log.String(), "Create cell file_reader") || !strings.Contains(log.String(), "Insert bytes_converter") {
+		t.Errorf("build messages not written to the log:\n%v", log.String())
+	}
+	expected := map[string][]string{
+		EXPORT_DOT: {
+			`c0 [label="file_reader(src)\nreader\nname=in.ts"];`,
+			`c2 -> c1 [label="ts_packets\nqueue capacity=100"];`,
+			`c3 -> c1 [label="out -> b\nts_packets"];`,
+		},
+		EXPORT_MERMAID: {
+			`c0["file_reader(src)<br/>reader<br/>name=in.ts"]`,
+			`c0 -- "[]byte" --> c2`,
+			`c3 -- "out -> b<br/>ts_packets" --> c1`,
+		},
+	}
+	for format, lines := range expected {
+		var b strings.Builder
+		if err := g.Export(&b, format); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range lines {
+			if !strings.Contains(b.String(), line) {
+				t.Errorf("%v diagram does not contain %v:\n%v", format, line, b.String())
+			}
+		}
+	}
+	if err := g.Export(&strings.Builder{}, "svg"); err == nil {
+		t.Error("expected failure for unknown format")
+	}
+}
@@ -0,0 +1,259 @@
+package graph
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/potterxu/tsanalyzer/internal/cell"
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"gopkg.in/yaml.v3"
+)
+
+/* Pipeline definition file in YAML or JSON
+ *
+ * cells:
+ *   - cell: file_reader
+ *     id: src
+ *     properties:
+ *       name: input file.ts
+ *   - cell: vbv
+ *     properties: {pcr: 32, pids: "32,33"}
+ * connections:           # optional, cells are connected in sequence if omitted
+ *   - from: src          # "id" or "id.port"
+ *     to: vbv_cell.in
+ *     capacity: 1000     # optional queue of the connection
+ *     policy: drop-oldest
+ */
+const (
+	file_key_cells       = "cells"
+	file_key_connections = "connections"
+	file_key_cell        = "cell"
+	file_key_id          = "id"
+	file_key_properties  = "properties"
+	file_key_from        = "from"
+	file_key_to          = "to"
+	file_key_capacity    = icell.CONFIG_EDGE_CAPACITY
+	file_key_policy      = icell.CONFIG_EDGE_POLICY
+)
+
+// fileLoader collects every error found in the definition file
+type fileLoader struct {
+	filename string
+	desc     *graphDesc
+	ok       bool
+}
+
+func (l *fileLoader) errorf(node *yaml.Node, format string, a ...any) {
+	fmt.Printf("%v:%v: %v\n", l.filename, node.Line, fmt.Sprintf(format, a...))
+	l.ok = false
+}
+
+// getFileGraphDesc loads the graph description from a YAML or JSON file
+func getFileGraphDesc(filename string) (*graphDesc, bool) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Println(err)
+		return nil, false
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		fmt.Printf("%v: %v\n", filename, err)
+		return nil, false
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) < 1 {
+		fmt.Printf("%v: empty pipeline definition\n", filename)
+		return nil, false
+	}
+
+	l := &fileLoader{
+		filename: filename,
+		desc:     &graphDesc{},
+		ok:       true,
+	}
+	l.load(root.Content[0])
+	if !l.ok {
+		return nil, false
+	}
+	if _, ok := l.desc.sorted(); !ok {
+		fmt.Printf("%v: cycle found in pipeline\n", filename)
+		return nil, false
+	}
+	return l.desc, true
+}
+
+func (l *fileLoader) load(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		l.errorf(node, "pipeline definition must be a mapping")
+		return
+	}
+	var cells, connections *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case file_key_cells:
+			cells = value
+		case file_key_connections:
+			connections = value
+		default:
+			l.errorf(key, "unknown key %v", key.Value)
+		}
+	}
+	if cells == nil {
+		l.errorf(node, "no %v found", file_key_cells)
+		return
+	}
+
+	l.loadCells(cells)
+	if !l.ok {
+		return
+	}
+	if connections == nil {
+		// connect the cells in sequence
+		for i := 1; i < len(l.desc.cells); i++ {
+			l.desc.addLink(i-1, icell.DEFAULT_PORT, i, icell.DEFAULT_PORT, icell.EdgeOptions{})
+		}
+		return
+	}
+	l.loadConnections(connections)
+}
+
+func (l *fileLoader) loadCells(node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode || len(node.Content) < 1 {
+		l.errorf(node, "%v must be a non-empty list", file_key_cells)
+		return
+	}
+	ids := make(map[string]int)
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			l.errorf(item, "cell must be a mapping")
+			continue
+		}
+		var name, id, properties *yaml.Node
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			key, value := item.Content[i], item.Content[i+1]
+			switch key.Value {
+			case file_key_cell:
+				name = value
+			case file_key_id:
+				if l.isScalar(value) {
+					id = value
+				}
+			case file_key_properties:
+				properties = value
+			default:
+				l.errorf(key, "unknown key %v", key.Value)
+			}
+		}
+		if name == nil {
+			l.errorf(item, "%v not provided", file_key_cell)
+			continue
+		}
+		if !l.isScalar(name) {
+			continue
+		}
+		if !cell.Exists(name.Value) {
+			l.errorf(name, "cell %v not supported", name.Value)
+			continue
+		}
+		info := newCellInfo(name.Value)
+		info.filename, info.line = l.filename, item.Line
+		if properties != nil {
+			l.loadProperties(info, properties)
+		}
+		if id != nil {
+			if line, ok := ids[id.Value]; ok {
+				l.errorf(id, "duplicated cell id %v, first defined at line %v", id.Value, line)
+			}
+			ids[id.Value] = id.Line
+			info.addProperty(icell.CONFIG_CELL_ID, id.Value)
+		}
+		l.desc.addCell(info)
+	}
+}
+
+func (l *fileLoader) loadProperties(info *cellInfo, node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		l.errorf(node, "%v must be a mapping", file_key_properties)
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if !l.isScalar(key) || !l.isScalar(value) {
+			continue
+		}
+		if key.Value == icell.CONFIG_CELL_ID {
+			l.errorf(key, "use %v of the cell instead of property", file_key_id)
+			continue
+		}
+		info.addProperty(key.Value, value.Value)
+	}
+}
+
+func (l *fileLoader) loadConnections(node *yaml.Node) {
+	if node.Kind != yaml.SequenceNode {
+		l.errorf(node, "%v must be a list", file_key_connections)
+		return
+	}
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			l.errorf(item, "connection must be a mapping")
+			continue
+		}
+		var from, to *yaml.Node
+		queue := make(icell.Config)
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			key, value := item.Content[i], item.Content[i+1]
+			switch key.Value {
+			case file_key_from:
+				from = value
+			case file_key_to:
+				to = value
+			case file_key_capacity, file_key_policy:
+				if l.isScalar(value) {
+					queue[key.Value] = value.Value
+				}
+			default:
+				l.errorf(key, "unknown key %v", key.Value)
+			}
+		}
+		if from == nil || to == nil {
+			l.errorf(item, "connection requires %v and %v", file_key_from, file_key_to)
+			continue
+		}
+		opts := icell.EdgeOptions{}
+		if len(queue) > 0 {
+			var err error
+			if opts, err = icell.ParseEdgeOptions(queue); err != nil {
+				l.errorf(item, "%v", err)
+				continue
+			}
+		}
+		src, srcPort, srcOk := l.resolve(from)
+		dst, dstPort, dstOk := l.resolve(to)
+		if srcOk && dstOk {
+			l.desc.addLink(src, srcPort, dst, dstPort, opts)
+		}
+	}
+}
+
+// resolve parses "id" or "id.port"
+func (l *fileLoader) resolve(node *yaml.Node) (int, string, bool) {
+	if !l.isScalar(node) {
+		return -1, "", false
+	}
+	id, port, _ := strings.Cut(node.Value, token_ref)
+	index, ok := l.desc.findCell(id)
+	if !ok {
+		l.errorf(node, "no cell with id %v", id)
+	}
+	return index, port, ok
+}
+
+func (l *fileLoader) isScalar(node *yaml.Node) bool {
+	if node.Kind != yaml.ScalarNode {
+		l.errorf(node, "expected a single value")
+		return false
+	}
+	return true
+}
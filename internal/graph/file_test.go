@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+func writePipelineFile(t *testing.T, name, content string) string {
+	filename := path.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestFileGraphDescSequence(t *testing.T) {
+	filename := writePipelineFile(t, "pipeline.yaml", `
+cells:
+  - cell: file_reader
+    properties:
+      name: input file=1.ts
+  - cell: file_writer
+    properties: {name: out.ts}
+`)
+	desc, ok := getFileGraphDesc(filename)
+	if !ok {
+		t.Fatal("failed to load pipeline file")
+	}
+	if len(desc.cells) != 2 || len(desc.links) != 1 {
+		t.Fatalf("expected 2 cells and 1 link, but get %v cells and %v links\n", len(desc.cells), len(desc.links))
+	}
+	if desc.cells[0].config["name"] != "input file=1.ts" {
+		t.Errorf("property not matched, expected \"input file=1.ts\", but get %v\n", desc.cells[0].config["name"])
+	}
+}
+
+func TestFileGraphDescConnections(t *testing.T) {
+	filename := writePipelineFile(t, "pipeline.json", `{
+  "cells": [
+    {"cell": "file_reader", "id": "src", "properties": {"name": "in.ts"}},
+    {"cell": "file_writer", "id": "copy", "properties": {"name": "out.ts"}},
+    {"cell": "compare", "id": "cmp"}
+  ],
+  "connections": [
+    {"from": "src", "to": "copy"},
+    {"from": "src.out", "to": "cmp.b", "capacity": 100, "policy": "drop-oldest"}
+  ]
+}`)
+	desc, ok := getFileGraphDesc(filename)
+	if !ok {
+		t.Fatal("failed to load pipeline file")
+	}
+	expected := []linkInfo{{0, "", 1, "", icell.EdgeOptions{}}, {0, "out", 2, "b", icell.EdgeOptions{Capacity: 100, Policy: icell.POLICY_DROP_OLDEST}}}
+	if len(desc.links) != len(expected) {
+		t.Fatalf("expected %v links, but get %v\n", len(expected), len(desc.links))
+	}
+	for i, link := range desc.links {
+		if *link != expected[i] {
+			t.Errorf("link %v not matched, expected %v, but get %v\n", i, expected[i], *link)
+		}
+	}
+}
+
+func TestFileGraphDescInvalid(t *testing.T) {
+	invalids := []string{
+		"cells: []",
+		"cells:\n  - cell: not_a_cell",
+		"cells:\n  - id: a",
+		"cells:\n  - cell: file_reader\n    unknown: 1",
+		"cells:\n  - cell: file_reader\n    id: a\n  - cell: file_writer\n    id: a",
+		"cells:\n  - cell: file_reader\n    id: a\nconnections:\n  - from: a\n    to: b",
+		"cells:\n  - cell: file_reader\n    id: a\n  - cell: file_writer\n    id: b\nconnections:\n  - from: a\n    to: b\n    policy: leak",
+	}
+	for _, content := range invalids {
+		if _, ok := getFileGraphDesc(writePipelineFile(t, "pipeline.yaml", content)); ok {
+			t.Errorf("expected failure for %q\n", content)
+		}
+	}
+}
+
+func TestFileGraphCellError(t *testing.T) {
+	filename := writePipelineFile(t, "pipeline.yaml", `
+cells:
+  - cell: file_reader
+    properties: {name: in.ts}
+  - cell: vbv
+    properties: {pcr: 9000}
+`)
+	_, err := NewGraphFromFile(filename, os.Stdout)
+	if !errors.Is(err, errinfo.ErrInvalidCellConfig) {
+		t.Fatalf("expected invalid config, but get %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), filename+":5: vbv") {
+		t.Errorf("expected the position of the cell in %q", err)
+	}
+}
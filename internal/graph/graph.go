@@ -1,12 +1,12 @@
 package graph
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"os/signal"
-	"reflect"
-	"strings"
+	"io"
 	"sync"
+	"time"
 
 	"github.com/potterxu/tsanalyzer/internal/cell"
 	"github.com/potterxu/tsanalyzer/internal/cell/icell"
@@ -14,118 +14,249 @@ import (
 )
 
 type Graph interface {
-	Run()
+	// Run the graph until all cells finished, canceling ctx aborts all cells
+	// return the errors reported by cells as *errinfo.CellError
+	Run(ctx context.Context, opts Options) error
+
+	// Stop the source cells and let the rest of the graph drain,
+	// the graph is aborted if not finished within Options.DrainTimeout
+	Stop()
+
+	// Stats returns a snapshot of the cell and edge metrics, safe to call while running
+	Stats() Stats
+
+	// Export writes the resolved graph as EXPORT_DOT or EXPORT_MERMAID diagram
+	Export(w io.Writer, format string) error
+}
+
+// Options controls how the graph runs
+type Options struct {
+	FailFast     bool          // abort the whole graph on the first failed cell
+	DrainTimeout time.Duration // 0 waits for draining forever
 }
 
 type graph struct {
-	pipeline  []icell.ICell
-	stopChans []chan bool
+	desc  *graphDesc
+	cells []icell.ICell
+	log   io.Writer // messages of building and running the graph, e.g. the created and finished cells
+
+	logMutex sync.Mutex // the cells finish concurrently, the log may not be safe for concurrent writes
 
-	wg sync.WaitGroup
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+	opts    Options
+	cancel  context.CancelFunc // set while running
+	stopped bool               // Stop was called, possibly before Run
+	drain   *time.Timer        // aborts the graph not drained within Options.DrainTimeout
 }
 
-func getCellInfos(gDesc string) ([]*cellInfo, bool) {
-	cellDescList := strings.Split(strings.TrimSpace(gDesc), "!")
-	if len(cellDescList) < 1 {
-		fmt.Println("No cell description found")
-		return nil, false
-	}
-	cellInfos := make([]*cellInfo, len(cellDescList))
-	for i, cDesc := range cellDescList {
-		if len(cDesc) < 1 {
-			fmt.Println("No cell description before !")
-			return nil, false
-		}
-		cArgs := strings.Split(strings.TrimSpace(cDesc), " ")
-		if len(cArgs) < 1 {
-			fmt.Println("No cell description before !")
-			return nil, false
-		}
-		cellName := cArgs[0]
-		cellInfo := newCellInfo(cellName)
-
-		if len(cArgs) > 1 {
-			for _, property := range cArgs[1:] {
-				pArgs := strings.Split(strings.TrimSpace(property), "=")
-				if len(pArgs) != 2 {
-					fmt.Printf("Invalid property for cell %v: %v\n", cellName, property)
-					cell.CellHelper(cellName)
-					return nil, false
-				}
-				cellInfo.addProperty(pArgs[0], pArgs[1])
-			}
-		}
-		cellInfos[i] = cellInfo
-	}
-	fmt.Printf("Create pipeline: ")
-	for i, info := range cellInfos {
-		if i == 0 {
-			fmt.Printf("%v ", info.name)
-		} else {
-			fmt.Printf("-> %v ", info.name)
+func connectGraph(desc *graphDesc, cells []icell.ICell) error {
+	for _, link := range desc.links {
+		if err := cells[link.src].Connect(link.srcPort, cells[link.dst], link.dstPort, link.opts); err != nil {
+			return fmt.Errorf("%w: %v to %v: %w", errinfo.ErrFailedToConnectCell,
+				portString(desc.cells[link.src], link.srcPort),
+				portString(desc.cells[link.dst], link.dstPort),
+				err)
 		}
 	}
-	fmt.Println()
-	return cellInfos, true
+	return nil
 }
 
-func connectPipeline(pipeline []icell.ICell) error {
-	for i := range pipeline {
-		if i >= len(pipeline)-1 {
-			break
-		}
-		if err := pipeline[i].Connect(pipeline[i+1]); err != nil {
-			return err
-		}
+// NewGraph builds the graph from the pipe syntax, the messages of building and running it are written to log
+func NewGraph(gDesc string, log io.Writer) (Graph, error) {
+	desc, ok := getGraphDesc(gDesc)
+	if !ok {
+		return nil, errinfo.ErrFailedToBuildGraph
 	}
-	return nil
+	return newGraph(desc, log)
 }
 
-func NewGraph(gDesc string) (Graph, error) {
-	cellInfos, ok := getCellInfos(gDesc)
+// NewGraphFromFile builds the graph from a YAML or JSON pipeline definition file, see NewGraph
+func NewGraphFromFile(filename string, log io.Writer) (Graph, error) {
+	desc, ok := getFileGraphDesc(filename)
 	if !ok {
 		return nil, errinfo.ErrFailedToBuildGraph
 	}
+	return newGraph(desc, log)
+}
+
+func newGraph(desc *graphDesc, log io.Writer) (*graph, error) {
 	graph := &graph{
-		pipeline:  make([]icell.ICell, len(cellInfos)),
-		stopChans: make([]chan bool, len(cellInfos)),
+		desc:  &graphDesc{links: desc.links},
+		cells: make([]icell.ICell, 0, len(desc.cells)),
+		log:   log,
 	}
 
-	for i, info := range cellInfos {
-		graph.stopChans[i] = make(chan bool, 1)
-		var err error
-		fmt.Printf("Create cell %v: %v\n", info.name, info.config)
-		graph.pipeline[i], err = cell.NewCell(info.name, graph.stopChans[i], info.config)
-		if err != nil {
+	for _, info := range desc.cells {
+		if _, err := graph.addCell(info); err != nil {
 			return nil, err
 		}
 	}
 
-	if err := connectPipeline(graph.pipeline); err != nil {
+	if err := graph.build(); err != nil {
 		return nil, err
 	}
-
 	return graph, nil
 }
 
-func (g *graph) Run() {
+// build resolves and connects the links between the added cells
+func (g *graph) build() error {
+	if _, ok := g.desc.sorted(); !ok {
+		fmt.Fprintln(g.log, "Cycle found in pipeline")
+		return errinfo.ErrFailedToBuildGraph
+	}
+
+	if err := g.insertConverters(); err != nil {
+		return err
+	}
+	g.desc.print(g.log)
+
+	return connectGraph(g.desc, g.cells)
+}
+
+func (g *graph) addCell(info *cellInfo) (int, error) {
+	fmt.Fprintf(g.log, "Create cell %v: %v\n", info.name, info.config)
+	c, err := cell.NewCell(info.name, info.config)
+	if err != nil {
+		if pos := info.position(); pos != "" {
+			return -1, fmt.Errorf("%v: %v: %w", pos, info, err)
+		}
+		return -1, err
+	}
+	return g.addICell(info, c), nil
+}
+
+// addICell adds a constructed cell described by info
+func (g *graph) addICell(info *cellInfo, c icell.ICell) int {
+	g.cells = append(g.cells, c)
+	return g.desc.addCell(info)
+}
+
+// insertConverters replaces the links between incompatible ports
+// by a chain of registered converters
+func (g *graph) insertConverters() error {
+	links := g.desc.links
+	g.desc.links = make([]*linkInfo, 0, len(links))
+	for _, link := range links {
+		srcFormats, srcErr := g.cells[link.src].OutputFormats(link.srcPort)
+		dstFormats, dstErr := g.cells[link.dst].InputFormats(link.dstPort)
+		if srcErr != nil || dstErr != nil ||
+			len(srcFormats) == 0 || len(dstFormats) == 0 ||
+			len(icell.IntersectFormats(srcFormats, dstFormats)) > 0 {
+			// compatible, or left to connectGraph to report
+			g.desc.links = append(g.desc.links, link)
+			continue
+		}
+		path, ok := cell.FindConverterPath(srcFormats, dstFormats)
+		if !ok {
+			g.desc.links = append(g.desc.links, link)
+			continue
+		}
+
+		src, srcPort := link.src, link.srcPort
+		for _, step := range path {
+			info := newCellInfo(step.Name)
+			for k, v := range step.Config {
+				info.addProperty(k, v)
+			}
+			fmt.Fprintf(g.log, "Insert %v to convert %v to %v\n", info.name, step.From, step.To)
+			index, err := g.addCell(info)
+			if err != nil {
+				return err
+			}
+			g.desc.addLink(src, srcPort, index, icell.DEFAULT_PORT, icell.EdgeOptions{})
+			src, srcPort = index, icell.DEFAULT_PORT
+		}
+		// the queue applies to the connection into the destination
+		g.desc.addLink(src, srcPort, link.dst, link.dstPort, link.opts)
+	}
+	return nil
+}
+
+func (g *graph) Run(ctx context.Context, opts Options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g.mutex.Lock()
+	g.opts = opts
+	g.cancel = cancel
+	if g.stopped {
+		g.armDrain()
+	}
+	g.mutex.Unlock()
+	defer func() {
+		// the graph finished, not aborted by the drain timeout afterwards
+		g.mutex.Lock()
+		defer g.mutex.Unlock()
+		if g.drain != nil {
+			g.drain.Stop()
+		}
+		g.cancel = nil
+	}()
+
+	errs := make([]error, len(g.cells))
+
 	// Start running cell from the end of pipeline
-	for i := len(g.pipeline) - 1; i >= 0; i-- {
+	order, _ := g.desc.sorted()
+	for j := len(order) - 1; j >= 0; j-- {
+		i := order[j]
 		g.wg.Add(1)
-		go g.pipeline[i].Run()
 		go func(index int) {
-			<-g.stopChans[index]
-			fmt.Printf("Cell %v finished\n", reflect.TypeOf(g.pipeline[index]).Elem().Name())
-			g.wg.Done()
+			defer g.wg.Done()
+			info := g.desc.cells[index]
+			if err := g.cells[index].Run(ctx); err != nil {
+				g.logf("Cell %v failed: %v\n", info, err)
+				errs[index] = &errinfo.CellError{
+					Cell: info.name,
+					Id:   info.id(),
+					Err:  err,
+				}
+				if opts.FailFast {
+					cancel()
+				}
+				return
+			}
+			g.logf("Cell %v finished\n", info)
 		}(i)
 	}
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt)
-	go func() {
-		<-signalChan
-		// stop the first cell of the pipeline to stop the whole graph
-		g.pipeline[0].Stop()
-	}()
 	g.wg.Wait()
-	fmt.Println("Graph finished")
+	for _, e := range g.Stats().Edges {
+		if e.Dropped > 0 {
+			fmt.Fprintf(g.log, "Edge %v -> %v dropped %v units\n", e.Src, e.Dst, e.Dropped)
+		}
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(g.log, "Graph aborted")
+	} else {
+		fmt.Fprintln(g.log, "Graph finished")
+	}
+	return errors.Join(errs...)
+}
+
+func (g *graph) Stop() {
+	// stop the source cells, the rest of the graph
+	// finishes when their inputs are closed
+	for i, c := range g.cells {
+		if !g.desc.hasInput(i) {
+			c.Stop()
+		}
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.stopped = true
+	g.armDrain()
+}
+
+// logf writes a message of the running graph to the log
+func (g *graph) logf(format string, a ...any) {
+	g.logMutex.Lock()
+	defer g.logMutex.Unlock()
+	fmt.Fprintf(g.log, format, a...)
+}
+
+// armDrain starts the drain timeout once the graph is running, called with the mutex locked
+func (g *graph) armDrain() {
+	if g.cancel != nil && g.drain == nil && g.opts.DrainTimeout > 0 {
+		g.drain = time.AfterFunc(g.opts.DrainTimeout, g.cancel)
+	}
 }
@@ -0,0 +1,235 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+)
+
+// testCell runs fn, started is closed once it runs
+type testCell struct {
+	icell.Cell
+
+	fn      func(c *testCell, ctx context.Context) error
+	started chan struct{}
+}
+
+func newTestCell(input, output icell.Format, fn func(c *testCell, ctx context.Context) error) *testCell {
+	c := &testCell{fn: fn, started: make(chan struct{})}
+	c.ICell = c
+	c.Init(icell.Config{})
+	if input != "" {
+		c.AddInputPort(icell.INPUT_PORT, input)
+	}
+	if output != "" {
+		c.AddOutputPort(icell.OUTPUT_PORT, output)
+	}
+	return c
+}
+
+func (c *testCell) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+	close(c.started)
+	return c.fn(c, ctx)
+}
+
+// produce sends units until stopped
+func produce(c *testCell, ctx context.Context) error {
+	for c.Running() {
+		c.PutOutput(icell.NewCellUnit("unit", icell.STRING))
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+// consume receives the units until the input is closed
+func consume(c *testCell, ctx context.Context) error {
+	for {
+		if _, ok := c.GetInput(); !ok {
+			return nil
+		}
+	}
+}
+
+// block ignores the input and the stop until aborted
+func block(c *testCell, ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func fail(err error) func(c *testCell, ctx context.Context) error {
+	return func(c *testCell, ctx context.Context) error {
+		return err
+	}
+}
+
+// runAsync runs the graph, the result is sent to the returned channel
+func runAsync(g Graph, opts Options) chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- g.Run(context.Background(), opts)
+	}()
+	return result
+}
+
+func waitResult(t *testing.T, result chan error, timeout time.Duration) error {
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		t.Fatalf("graph not finished within %v", timeout)
+		return nil
+	}
+}
+
+func TestGraphFormatMismatch(t *testing.T) {
+	b := NewBuilder()
+	src := b.AddCell("text_source", newTestCell("", icell.STRING, produce))
+	dst := b.AddCell("packet_sink", newTestCell(icell.TS_PACKET, "", consume))
+	if err := b.Connect(src, icell.DEFAULT_PORT, dst, icell.DEFAULT_PORT, icell.EdgeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := b.Build()
+	if !errors.Is(err, errinfo.ErrFormatMismatch) || !errors.Is(err, errinfo.ErrFailedToConnectCell) {
+		t.Fatalf("expected format mismatch, but get %v", err)
+	}
+	if !strings.Contains(err.Error(), "text_source") || !strings.Contains(err.Error(), "packet_sink") {
+		t.Errorf("expected both cells in %q", err)
+	}
+}
+
+func TestGraphCellErrors(t *testing.T) {
+	errA, errB := errors.New("a failed"), errors.New("b failed")
+	b := NewBuilder()
+	b.AddCell("a", newTestCell("", "", fail(errA)))
+	b.AddCell("b", newTestCell("", "", fail(errB)))
+	b.AddCell("ok", newTestCell("", "", fail(nil)))
+	g, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = waitResult(t, runAsync(g, Options{}), time.Second)
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("expected errors of both cells, but get %v", err)
+	}
+	errs := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, but get %v", errs)
+	}
+	for _, e := range errs {
+		var cellErr *errinfo.CellError
+		if !errors.As(e, &cellErr) || (cellErr.Cell == "a") != errors.Is(e, errA) {
+			t.Errorf("error not reported by its cell: %v", e)
+		}
+	}
+}
+
+func TestGraphFailFast(t *testing.T) {
+	errFailed := errors.New("failed")
+	b := NewBuilder()
+	blocked := newTestCell("", "", block)
+	b.AddCell("blocked", blocked)
+	b.AddCell("failed", newTestCell("", "", func(c *testCell, ctx context.Context) error {
+		// fail once the other cell runs
+		<-blocked.started
+		return errFailed
+	}))
+	g, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the blocked cell only finishes when the graph is torn down
+	err = waitResult(t, runAsync(g, Options{FailFast: true}), time.Second)
+	var cellErr *errinfo.CellError
+	if !errors.As(err, &cellErr) || cellErr.Cell != "failed" || !errors.Is(err, errFailed) {
+		t.Errorf("expected the error of the failed cell only, but get %v", err)
+	}
+}
+
+func TestGraphStopDrain(t *testing.T) {
+	received := 0
+	b := NewBuilder()
+	src := newTestCell("", icell.STRING, produce)
+	srcIndex := b.AddCell("source", src)
+	dst := b.AddCell("sink", newTestCell(icell.STRING, "", func(c *testCell, ctx context.Context) error {
+		for {
+			if _, ok := c.GetInput(); !ok {
+				return nil
+			}
+			received++
+		}
+	}))
+	if err := b.Connect(srcIndex, icell.DEFAULT_PORT, dst, icell.DEFAULT_PORT, icell.EdgeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := runAsync(g, Options{DrainTimeout: time.Second})
+	<-src.started
+	time.Sleep(10 * time.Millisecond)
+	g.Stop()
+	if err := waitResult(t, result, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// every unit sent before the source stopped is drained
+	if sent := g.Stats().Edges[0].Units; received == 0 || uint64(received) != sent {
+		t.Errorf("expected every unit drained, sent %v, received %v", sent, received)
+	}
+	if g.(*graph).drain.Stop() {
+		t.Error("drain timeout still armed after the graph finished")
+	}
+}
+
+func TestGraphDrainTimeout(t *testing.T) {
+	b := NewBuilder()
+	src := newTestCell("", icell.STRING, produce)
+	srcIndex := b.AddCell("source", src)
+	dst := b.AddCell("blocked", newTestCell(icell.STRING, "", block))
+	if err := b.Connect(srcIndex, icell.DEFAULT_PORT, dst, icell.DEFAULT_PORT, icell.EdgeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := 50 * time.Millisecond
+	result := runAsync(g, Options{DrainTimeout: timeout})
+	<-src.started
+	start := time.Now()
+	g.Stop()
+	// the blocked cell never drains, the graph is aborted after the timeout
+	if err := waitResult(t, result, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < timeout {
+		t.Errorf("aborted after %v, before the drain timeout %v", elapsed, timeout)
+	}
+}
+
+func TestGraphStopBeforeRun(t *testing.T) {
+	b := NewBuilder()
+	b.AddCell("source", newTestCell("", "", produce))
+	b.AddCell("blocked", newTestCell("", "", block))
+	g, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the drain timeout is armed once running
+	g.Stop()
+	if err := waitResult(t, runAsync(g, Options{DrainTimeout: 50 * time.Millisecond}), time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,237 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/potterxu/tsanalyzer/internal/cell"
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+)
+
+const (
+	token_link   = "!"
+	token_ref    = "."
+	token_assign = "="
+	token_queue  = "queue"
+)
+
+// endpoint refers to a port of a cell either by index or by user defined id
+type endpoint struct {
+	index int
+	ref   string
+	port  string
+}
+
+var noEndpoint = endpoint{index: -1}
+
+// pendingLink is a connection waiting for the ids to be resolved
+type pendingLink struct {
+	src   endpoint
+	dst   endpoint
+	queue icell.Config // properties of the queue, nil if not declared
+}
+
+func (ep endpoint) valid() bool {
+	return ep.index >= 0 || ep.ref != ""
+}
+
+func isReference(token string) bool {
+	return !strings.HasPrefix(token, token_ref) &&
+		strings.Contains(token, token_ref) &&
+		!strings.Contains(token, token_assign)
+}
+
+// parseReference parses "id." or "id.port"
+func parseReference(token string) endpoint {
+	id, port, _ := strings.Cut(token, token_ref)
+	return endpoint{index: -1, ref: id, port: port}
+}
+
+/* getGraphDesc parses the pipe syntax into a graph description
+ * cell prop=val ! cell prop=val       : connect cells in sequence
+ * cell id=name ...  name. ! cell      : branch from a named cell (tee)
+ * ... name.port ! cell                : branch from a named output port
+ * cell ! name.port                    : connect to a named input port (fan-in)
+ * cell ! queue capacity=N policy=P ! cell : configure the queue of the connection
+ */
+func getGraphDesc(gDesc string) (*graphDesc, bool) {
+	tokens := strings.Fields(strings.ReplaceAll(gDesc, token_link, " "+token_link+" "))
+	if len(tokens) < 1 {
+		fmt.Println("No cell description found")
+		return nil, false
+	}
+
+	desc := &graphDesc{}
+	links := make([]pendingLink, 0)
+
+	var current *cellInfo  // cell accepting properties
+	prev := noEndpoint     // upstream of the next cell
+	linked := false        // whether "!" follows prev
+	dangling := ""         // reference waiting for "!"
+	var queue icell.Config // queue waiting for "!"
+	for _, token := range tokens {
+		if dangling != "" && token != token_link {
+			fmt.Printf("Reference %v must be followed by %v\n", dangling, token_link)
+			return nil, false
+		}
+		if queue != nil && !linked && token != token_link && !strings.Contains(token, token_assign) {
+			fmt.Printf("%v must be followed by %v\n", token_queue, token_link)
+			return nil, false
+		}
+		switch {
+		case token == token_link:
+			if !prev.valid() {
+				fmt.Println("No cell description before !")
+				return nil, false
+			}
+			if linked {
+				fmt.Println("No cell description between !")
+				return nil, false
+			}
+			linked = true
+			dangling = ""
+			current = nil
+		case token == token_queue:
+			if !linked || queue != nil {
+				fmt.Printf("%v must be declared between cells, e.g. cell ! %v capacity=100 ! cell\n", token_queue, token_queue)
+				return nil, false
+			}
+			queue = make(icell.Config)
+			linked = false
+			current = nil
+		case isReference(token):
+			ref := parseReference(token)
+			if linked {
+				// connect to an input port of the named cell
+				links = append(links, pendingLink{prev, ref, queue})
+				ref.port = icell.DEFAULT_PORT
+				linked = false
+				queue = nil
+			} else {
+				dangling = token
+			}
+			prev = ref
+			current = nil
+		case strings.Contains(token, token_assign):
+			pArgs := strings.Split(token, token_assign)
+			if queue != nil && !linked {
+				if len(pArgs) != 2 {
+					fmt.Printf("Invalid property for %v: %v\n", token_queue, token)
+					return nil, false
+				}
+				queue[pArgs[0]] = pArgs[1]
+				continue
+			}
+			if current == nil {
+				fmt.Printf("Property %v does not belong to any cell\n", token)
+				return nil, false
+			}
+			if len(pArgs) != 2 {
+				fmt.Printf("Invalid property for cell %v: %v\n", current.name, token)
+				cell.CellHelper(current.name)
+				return nil, false
+			}
+			current.addProperty(pArgs[0], pArgs[1])
+		default:
+			current = newCellInfo(token)
+			index := desc.addCell(current)
+			if linked {
+				links = append(links, pendingLink{prev, endpoint{index: index}, queue})
+				queue = nil
+			}
+			prev = endpoint{index: index}
+			linked = false
+		}
+	}
+	if dangling != "" {
+		fmt.Printf("Reference %v must be followed by %v\n", dangling, token_link)
+		return nil, false
+	}
+	if linked || queue != nil {
+		fmt.Println("No cell description after !")
+		return nil, false
+	}
+
+	if !desc.checkIds() {
+		return nil, false
+	}
+	for _, link := range links {
+		src, ok := desc.resolve(link.src)
+		if !ok {
+			return nil, false
+		}
+		dst, ok := desc.resolve(link.dst)
+		if !ok {
+			return nil, false
+		}
+		opts := icell.EdgeOptions{}
+		if link.queue != nil {
+			var err error
+			if opts, err = icell.ParseEdgeOptions(link.queue); err != nil {
+				fmt.Println(err)
+				return nil, false
+			}
+		}
+		desc.addLink(src, link.src.port, dst, link.dst.port, opts)
+	}
+	if _, ok := desc.sorted(); !ok {
+		fmt.Println("Cycle found in pipeline")
+		return nil, false
+	}
+	return desc, true
+}
+
+func (gd *graphDesc) checkIds() bool {
+	ids := make(map[string]bool)
+	for _, info := range gd.cells {
+		id := info.id()
+		if id == "" {
+			continue
+		}
+		if ids[id] {
+			fmt.Printf("Duplicated cell id %v\n", id)
+			return false
+		}
+		ids[id] = true
+	}
+	return true
+}
+
+func (gd *graphDesc) resolve(ep endpoint) (int, bool) {
+	if ep.index >= 0 {
+		return ep.index, true
+	}
+	index, ok := gd.findCell(ep.ref)
+	if !ok {
+		fmt.Printf("No cell with id %v\n", ep.ref)
+	}
+	return index, ok
+}
+
+// print writes the cells and connections of the graph to w
+func (gd *graphDesc) print(w io.Writer) {
+	fmt.Fprintln(w, "Create pipeline:")
+	for i, info := range gd.cells {
+		if !gd.hasLink(i) {
+			fmt.Fprintf(w, "  %v\n", info)
+		}
+	}
+	for _, link := range gd.links {
+		queue := ""
+		if opts := link.opts.String(); opts != "" {
+			queue = fmt.Sprintf(" [%v %v]", token_queue, opts)
+		}
+		fmt.Fprintf(w, "  %v -> %v%v\n",
+			portString(gd.cells[link.src], link.srcPort),
+			portString(gd.cells[link.dst], link.dstPort),
+			queue)
+	}
+}
+
+func portString(info *cellInfo, port string) string {
+	if port == icell.DEFAULT_PORT {
+		return info.String()
+	}
+	return fmt.Sprintf("%v.%v", info, port)
+}
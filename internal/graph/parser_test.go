@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+)
+
+func TestGraphDescPipeline(t *testing.T) {
+	desc, ok := getGraphDesc("file_reader name=in.ts ! bytes_converter output_format=ts_packet ! file_writer name=out.ts")
+	if !ok {
+		t.Fatal("failed to parse pipeline")
+	}
+	if len(desc.cells) != 3 || len(desc.links) != 2 {
+		t.Fatalf("expected 3 cells and 2 links, but get %v cells and %v links\n", len(desc.cells), len(desc.links))
+	}
+	if desc.cells[0].config["name"] != "in.ts" {
+		t.Errorf("property not matched, expected in.ts, but get %v\n", desc.cells[0].config["name"])
+	}
+}
+
+func TestGraphDescTee(t *testing.T) {
+	desc, ok := getGraphDesc("file_reader name=in.ts id=src ! file_writer name=out.ts src. ! bytes_converter output_format=ts_packet ! vbv pcr=32 pids=32")
+	if !ok {
+		t.Fatal("failed to parse tee")
+	}
+	expected := []linkInfo{{0, "", 1, "", icell.EdgeOptions{}}, {0, "", 2, "", icell.EdgeOptions{}}, {2, "", 3, "", icell.EdgeOptions{}}}
+	if len(desc.links) != len(expected) {
+		t.Fatalf("expected %v links, but get %v\n", len(expected), len(desc.links))
+	}
+	for i, link := range desc.links {
+		if *link != expected[i] {
+			t.Errorf("link %v not matched, expected %v, but get %v\n", i, expected[i], *link)
+		}
+	}
+}
+
+func TestGraphDescFanIn(t *testing.T) {
+	desc, ok := getGraphDesc("mcast_reader intf=eth0 addr=239.1.1.1:1000 ! bytes_converter output_format=ts_packet ! compare id=cmp " +
+		"mcast_reader intf=eth1 addr=239.1.1.2:1000 ! bytes_converter output_format=ts_packet ! cmp.b")
+	if !ok {
+		t.Fatal("failed to parse fan-in")
+	}
+	expected := []linkInfo{{0, "", 1, "", icell.EdgeOptions{}}, {1, "", 2, "", icell.EdgeOptions{}}, {3, "", 4, "", icell.EdgeOptions{}}, {4, "", 2, "b", icell.EdgeOptions{}}}
+	if len(desc.links) != len(expected) {
+		t.Fatalf("expected %v links, but get %v\n", len(expected), len(desc.links))
+	}
+	for i, link := range desc.links {
+		if *link != expected[i] {
+			t.Errorf("link %v not matched, expected %v, but get %v\n", i, expected[i], *link)
+		}
+	}
+}
+
+func TestGraphDescQueue(t *testing.T) {
+	desc, ok := getGraphDesc("mcast_reader intf=eth0 addr=239.1.1.1:1000 id=src ! queue capacity=1000 policy=drop-oldest ! file_writer name=out.ts " +
+		"src. ! queue policy=drop-newest ! bytes_converter")
+	if !ok {
+		t.Fatal("failed to parse queue")
+	}
+	expected := []linkInfo{
+		{0, "", 1, "", icell.EdgeOptions{Capacity: 1000, Policy: icell.POLICY_DROP_OLDEST}},
+		{0, "", 2, "", icell.EdgeOptions{Capacity: icell.EDGE_BUFFER, Policy: icell.POLICY_DROP_NEWEST}},
+	}
+	if len(desc.cells) != 3 || len(desc.links) != len(expected) {
+		t.Fatalf("expected 3 cells and %v links, but get %v cells and %v links\n", len(expected), len(desc.cells), len(desc.links))
+	}
+	for i, link := range desc.links {
+		if *link != expected[i] {
+			t.Errorf("link %v not matched, expected %v, but get %v\n", i, expected[i], *link)
+		}
+	}
+}
+
+func TestGraphDescInvalid(t *testing.T) {
+	invalids := []string{
+		"",
+		"! file_writer name=out.ts",
+		"file_reader name=in.ts !",
+		"file_reader name=in.ts ! ! file_writer name=out.ts",
+		"file_reader name=in.ts src. ! file_writer name=out.ts",
+		"file_reader name=in.ts id=src src. file_writer name=out.ts",
+		"file_reader id=a ! file_writer id=a",
+		"file_reader name=in.ts ! b.",
+		"bytes_converter id=a ! bytes_converter ! a.",
+		"queue ! file_writer name=out.ts",
+		"file_reader name=in.ts ! queue capacity=10",
+		"file_reader name=in.ts ! queue capacity=10 file_writer name=out.ts",
+		"file_reader name=in.ts ! queue ! queue ! file_writer name=out.ts",
+		"file_reader name=in.ts ! queue capacity=0 ! file_writer name=out.ts",
+		"file_reader name=in.ts ! queue policy=leak ! file_writer name=out.ts",
+	}
+	for _, gDesc := range invalids {
+		if _, ok := getGraphDesc(gDesc); ok {
+			t.Errorf("expected failure for %q\n", gDesc)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+)
+
+// Stats is a snapshot of the runtime metrics of a graph
+type Stats struct {
+	Cells []CellStats
+	Edges []EdgeStats
+}
+
+type CellStats struct {
+	Cell string // name(id) of the cell
+	icell.CellStats
+}
+
+type EdgeStats struct {
+	Src string // name(id).port of the upstream cell
+	Dst string // name(id).port of the downstream cell
+	icell.EdgeStats
+}
+
+// Stats collects the metrics of the cells and edges in topological order
+func (g *graph) Stats() Stats {
+	index := make(map[icell.ICell]int, len(g.cells))
+	for i, c := range g.cells {
+		index[c] = i
+	}
+
+	stats := Stats{}
+	order, _ := g.desc.sorted()
+	for _, i := range order {
+		info := g.desc.cells[i]
+		stats.Cells = append(stats.Cells, CellStats{
+			Cell:      info.String(),
+			CellStats: g.cells[i].Stats(),
+		})
+		for _, e := range g.cells[i].OutputEdges() {
+			dst, ok := index[e.Dst()]
+			if !ok {
+				continue
+			}
+			stats.Edges = append(stats.Edges, EdgeStats{
+				Src:       portString(info, e.SrcPort()),
+				Dst:       portString(g.desc.cells[dst], e.DstPort()),
+				EdgeStats: e.Stats(),
+			})
+		}
+	}
+	return stats
+}
+
+// Print writes the metrics as tables,
+// the busiest cell and the edges blocking longest point to the bottleneck
+func (s Stats) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "cell\telapsed\tbusy\tbusy%\tinput wait\toutput wait")
+	for _, c := range s.Cells {
+		percent := 0.0
+		if c.Elapsed > 0 {
+			percent = float64(c.Busy()) * 100 / float64(c.Elapsed)
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%.1f\t%v\t%v\n",
+			c.Cell, round(c.Elapsed), round(c.Busy()), percent,
+			round(c.InputWait), round(c.OutputWait))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "edge\tunits\tbytes\tdepth\tmax depth\tblocked\tpolicy\tdropped")
+	for _, e := range s.Edges {
+		fmt.Fprintf(tw, "%v -> %v\t%v\t%v\t%v/%v\t%v\t%v\t%v\t%v\n",
+			e.Src, e.Dst, e.Units, e.Bytes, e.Depth, e.Capacity, e.MaxDepth, round(e.Blocked), e.Policy, e.Dropped)
+	}
+	tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
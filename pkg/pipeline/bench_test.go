@@ -0,0 +1,58 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/potterxu/tsanalyzer/pkg/pipeline"
+)
+
+const benchFileSize = 64 << 20
+
+// writeBenchFile repeats the test file up to benchFileSize bytes
+func writeBenchFile(b *testing.B) string {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	filename := path.Join(b.TempDir(), "bench.ts")
+	content := bytes.Repeat(data, benchFileSize/len(data))
+	if err := os.WriteFile(filename, content, 0644); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(content)))
+	return filename
+}
+
+func benchmarkPackets(b *testing.B, format pipeline.Format) {
+	filename := writeBenchFile(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := pipeline.New()
+		reader, err := p.Add("file_reader", pipeline.Config{"name": filename})
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink := p.Sink(func(unit pipeline.CellUnit) error {
+			return nil
+		}, format)
+		if err := p.Connect(reader, sink); err != nil {
+			b.Fatal(err)
+		}
+		if err := p.Run(context.Background(), pipeline.Options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// go test -bench . ./pkg/pipeline
+func BenchmarkTsPacket(b *testing.B) {
+	benchmarkPackets(b, pipeline.TS_PACKET)
+}
+
+func BenchmarkTsPackets(b *testing.B) {
+	benchmarkPackets(b, pipeline.TS_PACKETS)
+}
@@ -0,0 +1,170 @@
+/*
+Package pipeline builds and runs cell graphs from Go code
+
+	p := pipeline.New()
+	reader, _ := p.Add("file_reader", pipeline.Config{"name": "input.ts"})
+	sink := p.Sink(func(unit pipeline.CellUnit) error {
+		pkt := unit.Data().(packet.Packet)
+		...
+		return nil
+	}, pipeline.TS_PACKET)
+	p.Connect(reader, sink) // bytes_converter is inserted automatically
+	err := p.Run(context.Background(), pipeline.Options{})
+*/
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+	"github.com/potterxu/tsanalyzer/internal/graph"
+)
+
+// Pipeline is a graph of cells under construction
+type Pipeline struct {
+	builder  *graph.Builder
+	registry map[string]Constructor
+
+	mutex sync.Mutex
+	graph graph.Graph
+}
+
+// Node refers to a cell added to the pipeline
+type Node struct {
+	p     *Pipeline
+	index int
+	name  string
+}
+
+func (n *Node) Name() string {
+	return n.name
+}
+
+func New() *Pipeline {
+	return &Pipeline{
+		builder:  graph.NewBuilder(),
+		registry: make(map[string]Constructor),
+	}
+}
+
+// SetLog sets where the messages of building and running the pipeline are written,
+// e.g. the created and finished cells, os.Stdout by default, io.Discard to keep quiet.
+// The cells report their results to os.Stdout
+func (p *Pipeline) SetLog(w io.Writer) {
+	p.builder.SetLog(w)
+}
+
+// Register makes a custom cell available to Add of this pipeline
+func (p *Pipeline) Register(name string, ctor Constructor) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.registry[name] = ctor
+}
+
+// Add creates a cell by name, either registered to this pipeline or built in
+func (p *Pipeline) Add(name string, config Config) (*Node, error) {
+	p.mutex.Lock()
+	ctor, ok := p.registry[name]
+	p.mutex.Unlock()
+	if ok {
+		return p.AddFunc(name, ctor, config)
+	}
+	index, err := p.builder.Add(name, config)
+	if err != nil {
+		return nil, err
+	}
+	return p.node(index, name), nil
+}
+
+// AddFunc creates a cell by its constructor, name is used for reporting
+func (p *Pipeline) AddFunc(name string, ctor Constructor, config Config) (*Node, error) {
+	c, err := ctor(config)
+	if err != nil {
+		return nil, err
+	}
+	return p.AddCell(name, c), nil
+}
+
+// AddCell adds a constructed cell, name is used for reporting
+func (p *Pipeline) AddCell(name string, c ICell) *Node {
+	return p.node(p.builder.AddCell(name, c), name)
+}
+
+// Sink adds a cell passing every received unit to fn,
+// formats restricts the accepted formats, empty to accept any
+func (p *Pipeline) Sink(fn SinkFunc, formats ...Format) *Node {
+	return p.AddCell("sink", newSink(fn, formats...))
+}
+
+// Connect the default output port of src to the default input port of dst
+func (p *Pipeline) Connect(src, dst *Node) error {
+	return p.ConnectPorts(src, DEFAULT_PORT, dst, DEFAULT_PORT)
+}
+
+// ConnectPorts connects the named ports, DEFAULT_PORT refers to the first port of the cell
+func (p *Pipeline) ConnectPorts(src *Node, srcPort string, dst *Node, dstPort string) error {
+	return p.ConnectQueue(src, srcPort, dst, dstPort, EdgeOptions{})
+}
+
+// ConnectQueue connects the named ports with the queue configured by opts,
+// e.g. EdgeOptions{Capacity: 1000, Policy: POLICY_DROP_OLDEST} for live sources
+func (p *Pipeline) ConnectQueue(src *Node, srcPort string, dst *Node, dstPort string, opts EdgeOptions) error {
+	if src == nil || dst == nil || src.p != p || dst.p != p {
+		return fmt.Errorf("%w: node not in the pipeline", errinfo.ErrFailedToConnectCell)
+	}
+	return p.builder.Connect(src.index, srcPort, dst.index, dstPort, opts)
+}
+
+// Chain connects the nodes in sequence
+func (p *Pipeline) Chain(nodes ...*Node) error {
+	for i := 1; i < len(nodes); i++ {
+		if err := p.Connect(nodes[i-1], nodes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run builds the pipeline and runs it until all cells finished,
+// the returned error joins a *CellError for every failed cell
+func (p *Pipeline) Run(ctx context.Context, opts Options) error {
+	g, err := p.builder.Build()
+	if err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	p.graph = g
+	p.mutex.Unlock()
+	return g.Run(ctx, opts)
+}
+
+// Stop the sources of a running pipeline and let the rest drain
+func (p *Pipeline) Stop() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.graph != nil {
+		p.graph.Stop()
+	}
+}
+
+// Stats returns a snapshot of the cell and edge metrics,
+// empty if the pipeline is not running yet
+func (p *Pipeline) Stats() Stats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.graph == nil {
+		return Stats{}
+	}
+	return p.graph.Stats()
+}
+
+func (p *Pipeline) node(index int, name string) *Node {
+	return &Node{
+		p:     p,
+		index: index,
+		name:  name,
+	}
+}
@@ -0,0 +1,444 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Comcast/gots/v2/packet"
+	"github.com/potterxu/tsanalyzer/pkg/pipeline"
+)
+
+const testFile = "../../tsutil/data/data.ts"
+
+// registrations counts the cells registered by TestRegister
+var registrations atomic.Int32
+
+// counter is a custom cell counting the packets of a pid
+type counter struct {
+	pipeline.Cell
+
+	pid   int
+	count int
+}
+
+func newCounter(config pipeline.Config) (pipeline.ICell, error) {
+	c := &counter{pid: 48}
+	c.ICell = c
+	c.Init(config)
+	c.AddInputPort(pipeline.INPUT_PORT, pipeline.TS_PACKET)
+	c.AddOutputPort(pipeline.OUTPUT_PORT, pipeline.TS_PACKET)
+	return c, nil
+}
+
+func (c *counter) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+	for {
+		unit, ok := c.GetInput()
+		if !ok {
+			return nil
+		}
+		pkt := unit.Data().(packet.Packet)
+		if packet.Pid(&pkt) == c.pid {
+			c.count++
+		}
+		c.PutOutput(unit)
+	}
+}
+
+func TestPipelineSink(t *testing.T) {
+	var log strings.Builder
+	p := pipeline.New()
+	p.SetLog(&log)
+	reader, err := p.Add("file_reader", pipeline.Config{"name": testFile, "id": "src"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets := 0
+	sink := p.Sink(func(unit pipeline.CellUnit) error {
+		metadata := unit.Metadata()
+		if metadata == nil {
+			return errors.New("no metadata")
+		}
+		if metadata.Source != "src" || metadata.Index != int64(packets) || metadata.Offset != int64(packets*188) {
+			return fmt.Errorf("metadata not match for packet %v: %+v", packets, *metadata)
+		}
+		packets++
+		return nil
+	}, pipeline.TS_PACKET)
+	if err := p.Connect(reader, sink); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(context.Background(), pipeline.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if packets != 20 {
+		t.Errorf("packet cnt not match, expected 20, but get %v\n", packets)
+	}
+	if !strings.Contains(log.String(), "Cell sink finished") || !strings.Contains(log.String(), "Graph finished") {
+		t.Errorf("messages of running not written to the log:\n%v", log.String())
+	}
+
+	// file_reader -> bytes_converter -> sink
+	stats := p.Stats()
+	if len(stats.Cells) != 3 || len(stats.Edges) != 2 {
+		t.Fatalf("stats not match, expected 3 cells and 2 edges, but get %v\n", stats)
+	}
+	if stats.Edges[0].Bytes != 20*188 {
+		t.Errorf("edge bytes not match, expected %v, but get %v\n", 20*188, stats.Edges[0].Bytes)
+	}
+	if stats.Edges[1].Units != 20 {
+		t.Errorf("edge units not match, expected 20, but get %v\n", stats.Edges[1].Units)
+	}
+}
+
+func TestPipelineCustomCell(t *testing.T) {
+	p := pipeline.New()
+	p.Register("counter", newCounter)
+	reader, err := p.Add("file_reader", pipeline.Config{"name": testFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := p.Add("counter", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sinkErr := errors.New("stop")
+	sink := p.Sink(func(unit pipeline.CellUnit) error {
+		return sinkErr
+	})
+	if err := p.Chain(reader, node, sink); err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background(), pipeline.Options{})
+	var cellErr *pipeline.CellError
+	if !errors.As(err, &cellErr) || !errors.Is(err, sinkErr) || cellErr.Cell != "sink" {
+		t.Errorf("expected error from sink, but get %v\n", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	// the registry is global, a name per run allows running the test several times
+	r := pipeline.Registration{
+		Type: pipeline.PROCESSOR,
+		Name: fmt.Sprintf("test_counter_%v", registrations.Add(1)),
+		Ctor: newCounter,
+	}
+	if err := pipeline.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := pipeline.Register(r); err == nil {
+		t.Error("expected error for duplicated registration")
+	}
+
+	p := pipeline.New()
+	reader, err := p.Add("file_reader", pipeline.Config{"name": testFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := p.Add(r.Name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Chain(reader, node); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(context.Background(), pipeline.Options{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPipelinePes(t *testing.T) {
+	pes := make([]*pipeline.PesPacket, 0)
+	// file_reader -> bytes_converter -> pes_converter -> sink
+	_, err := countPackets(context.Background(), t, "file_reader", pipeline.Config{"name": testFile}, pipeline.PES_PACKET, func(unit pipeline.CellUnit) error {
+		pkt := unit.Data().(*pipeline.PesPacket)
+		if metadata := unit.Metadata(); metadata == nil || metadata.Index != pkt.FirstIndex {
+			return fmt.Errorf("metadata not match for pes %+v", pkt)
+		}
+		pes = append(pes, pkt)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the last video pes packet is ended by the end of the stream
+	expected := []pipeline.PesPacket{
+		{Pid: 48, StreamId: 0xe0, Length: 0, Pts: 41510221, Dts: -1, FirstIndex: 0, LastIndex: 11},
+		{Pid: 48, StreamId: 0xe0, Length: 0, Pts: 41512021, Dts: -1, FirstIndex: 15, LastIndex: 15},
+		{Pid: 80, StreamId: 0xbd, Length: 178, Pts: 41445421, Dts: -1, FirstIndex: 14, LastIndex: 14},
+	}
+	if len(pes) != len(expected) {
+		t.Fatalf("pes cnt not match, expected %v, but get %v\n", len(expected), len(pes))
+	}
+	for i, pkt := range pes {
+		e := expected[i]
+		if pkt.Pid != e.Pid || pkt.StreamId != e.StreamId || pkt.Length != e.Length || pkt.Pts != e.Pts || pkt.Dts != e.Dts ||
+			pkt.FirstIndex != e.FirstIndex || pkt.LastIndex != e.LastIndex {
+			t.Errorf("pes %v not match, expected %+v, but get %+v\n", i, e, *pkt)
+		}
+	}
+}
+
+// convertPes returns the pes packets of the file
+func convertPes(t *testing.T, name string) []*pipeline.PesPacket {
+	pes := make([]*pipeline.PesPacket, 0)
+	_, err := countPackets(context.Background(), t, "file_reader", pipeline.Config{"name": name}, pipeline.PES_PACKET, func(unit pipeline.CellUnit) error {
+		pes = append(pes, unit.Data().(*pipeline.PesPacket))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pes
+}
+
+func TestPesConverterContinuity(t *testing.T) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	video := convertPes(t, testFile)[0].Payload
+	cases := []struct {
+		name    string
+		data    []byte
+		pes     int     // pes packets expected
+		indexes []int64 // first indexes of the video pes packets
+	}{
+		// packet 3 of pid 48 repeated, the duplicate is ignored
+		{"duplicate", slices.Concat(data[:4*188], data[3*188:]), 3, []int64{0, 16}},
+		// packet 7 of pid 48 lost, the first video pes packet is dropped
+		{"lost", slices.Concat(data[:7*188], data[8*188:]), 2, []int64{14}},
+	}
+	for _, c := range cases {
+		name := filepath.Join(t.TempDir(), c.name+".ts")
+		if err := os.WriteFile(name, c.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		pes := convertPes(t, name)
+		indexes := make([]int64, 0)
+		for _, pkt := range pes {
+			if pkt.Pid == 48 {
+				indexes = append(indexes, pkt.FirstIndex)
+			}
+		}
+		if len(pes) != c.pes || !slices.Equal(indexes, c.indexes) {
+			t.Errorf("%v: expected %v pes packets, video at %v, but get %v, video at %v", c.name, c.pes, c.indexes, len(pes), indexes)
+		}
+		if len(pes) > 0 && pes[0].FirstIndex == 0 && !bytes.Equal(pes[0].Payload, video) {
+			t.Errorf("%v: payload of the first video pes packet not match", c.name)
+		}
+	}
+}
+
+func TestFileWriterMissingDir(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "missing", "dir", "out.ts")
+	p := pipeline.New()
+	reader, err := p.Add("file_reader", pipeline.Config{"name": testFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := p.Add("file_writer", pipeline.Config{"name": output})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Connect(reader, writer); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(context.Background(), pipeline.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := os.ReadFile(testFile)
+	if written, err := os.ReadFile(output); err != nil || !bytes.Equal(written, expected) {
+		t.Errorf("expected a copy of the input in the created directory, but get %v bytes, %v", len(written), err)
+	}
+}
+
+// batches is a custom source sending the packets of the test file in batches of 4 a millisecond apart
+type batches struct {
+	pipeline.Cell
+}
+
+func newBatches(config pipeline.Config) (pipeline.ICell, error) {
+	c := &batches{}
+	c.ICell = c
+	c.Init(config)
+	c.AddOutputPort(pipeline.OUTPUT_PORT, pipeline.TS_PACKETS)
+	return c, nil
+}
+
+func (c *batches) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(data)/(4*188); i++ {
+		pkts := make([]packet.Packet, 4)
+		for n := range pkts {
+			copy(pkts[n][:], data[(i*4+n)*188:])
+		}
+		metadata := pipeline.NewMetadata(c.Id())
+		metadata.Monotonic = time.Duration(i+1) * time.Millisecond
+		c.PutOutput(pipeline.NewCellUnitWithMetadata(pkts, pipeline.TS_PACKETS, metadata))
+	}
+	return nil
+}
+
+func TestFileWriterArrivalTimestamps(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.m2ts")
+	p := pipeline.New()
+	src, err := p.AddFunc("batches", newBatches, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := p.Add("file_writer", pipeline.Config{"name": output, "packet_size": "192"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Connect(src, writer); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(context.Background(), pipeline.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(output)
+	if err != nil || len(written) != 20*192 {
+		t.Fatalf("expected 20 packets of 192 bytes, but get %v bytes, %v", len(written), err)
+	}
+	// 27000 ticks a millisecond, the packets of the first batch share its receive time
+	for i := 0; i < 20; i++ {
+		expected := uint32(27000)
+		if batch, n := i/4, i%4; batch > 0 {
+			expected = uint32(27000*batch + 6750*(n+1))
+		}
+		if ats := binary.BigEndian.Uint32(written[i*192:]) & 0x3FFFFFFF; ats != expected {
+			t.Errorf("arrival time stamp of packet %v not match, expected %v, but get %v", i, expected, ats)
+		}
+	}
+}
+
+func TestPipelineInvalidPacketIndex(t *testing.T) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// invalid transport scrambling control in packet 5
+	data[5*188+3] = data[5*188+3]&0x3F | 0x40
+	name := filepath.Join(t.TempDir(), "invalid.ts")
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []pipeline.Format{pipeline.TS_PACKET, pipeline.TS_PACKETS} {
+		indexes := make([]int64, 0)
+		_, err := countPackets(context.Background(), t, "file_reader", pipeline.Config{"name": name}, format, func(unit pipeline.CellUnit) error {
+			switch pkts := unit.Data().(type) {
+			case packet.Packet:
+				indexes = append(indexes, unit.Metadata().Index)
+			case []packet.Packet:
+				for n := range pkts {
+					indexes = append(indexes, unit.Metadata().PacketAt(n).Index)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the packets after the invalid one keep their index in the source
+		if len(indexes) != 19 || indexes[4] != 4 || indexes[5] != 6 || indexes[18] != 19 {
+			t.Errorf("indexes of %v not match the source: %v", format, indexes)
+		}
+	}
+}
+
+// captureStdout returns what fn prints, e.g. the result of a cell
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	output := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		output <- string(b)
+	}()
+	fn()
+	os.Stdout = stdout
+	w.Close()
+	return <-output
+}
+
+func TestCompare(t *testing.T) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a payload byte of packet 7 differs
+	data[7*188+100] ^= 0xff
+	different := filepath.Join(t.TempDir(), "different.ts")
+	if err := os.WriteFile(different, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		expected []string
+	}{
+		{testFile, []string{"identical packets: 20", "different packets: 0"}},
+		{different, []string{"identical packets: 19", "different packets: 1", "first different indexes: [7]"}},
+	}
+	for _, c := range cases {
+		var log bytes.Buffer
+		p := pipeline.New()
+		p.SetLog(&log)
+		a, err := p.Add("file_reader", pipeline.Config{"name": testFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := p.Add("file_reader", pipeline.Config{"name": c.name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmp, err := p.Add("compare", pipeline.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the inserted bytes_converters batch the packets
+		if err := p.ConnectPorts(a, pipeline.DEFAULT_PORT, cmp, "a"); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.ConnectPorts(b, pipeline.DEFAULT_PORT, cmp, "b"); err != nil {
+			t.Fatal(err)
+		}
+		output := captureStdout(t, func() {
+			err = p.Run(context.Background(), pipeline.Options{})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(log.String(), "to ts_packets") {
+			t.Errorf("expected the packets batched, but get %q", log.String())
+		}
+		for _, e := range c.expected {
+			if !strings.Contains(output, e) {
+				t.Errorf("expected %q comparing %v, but get %q", e, c.name, output)
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"github.com/potterxu/tsanalyzer/internal/cell"
+)
+
+/*
+Register makes a custom cell available to every pipeline and to the pipe syntax,
+call it in the init() of the package defining the cell.
+The constructor creates the ports declared by the spec
+
+	var SeiParserSpec = pipeline.Spec{
+		Description: "Parse the SEI messages of the video",
+		Inputs:      []pipeline.PortSpec{{Name: pipeline.INPUT_PORT, Formats: []pipeline.Format{pipeline.TS_PACKET}}},
+		Outputs:     []pipeline.PortSpec{{Name: pipeline.OUTPUT_PORT, Formats: []pipeline.Format{pipeline.STRING}}},
+	}
+
+	func NewSeiParser(config pipeline.Config) (pipeline.ICell, error) {
+		c := &seiParser{} // embeds pipeline.Cell
+		c.ICell = c
+		c.Init(config)
+		c.InitPorts(&SeiParserSpec)
+		return c, nil
+	}
+
+	func init() {
+		if err := pipeline.Register(pipeline.Registration{
+			Type:  pipeline.PROCESSOR,
+			Name:  "sei_parser",
+			Ctor:  NewSeiParser,
+			Spec:  SeiParserSpec,
+			Short: SeiParserHelpShort,
+			Help:  SeiParserHelp,
+		}); err != nil {
+			panic(err)
+		}
+	}
+
+the cells then appear in "pipe --list" of a binary importing the package
+and running cmd.Execute() from github.com/potterxu/tsanalyzer/cmd.
+It is safe to call concurrently with building and running pipelines
+*/
+func Register(r Registration) error {
+	return cell.Register(r)
+}
+
+// RegisterConverter makes the cell inserted automatically
+// between cells producing from and cells accepting to, it is safe to call concurrently
+func RegisterConverter(name string, from, to Format, config Config) {
+	cell.RegisterConverter(name, from, to, config)
+}
@@ -0,0 +1,37 @@
+package pipeline
+
+import (
+	"context"
+)
+
+// SinkFunc receives the units of a sink, returning an error fails the sink
+type SinkFunc func(CellUnit) error
+
+type sink struct {
+	Cell
+
+	fn SinkFunc
+}
+
+func newSink(fn SinkFunc, formats ...Format) *sink {
+	c := &sink{fn: fn}
+	c.ICell = c
+	c.Init(Config{})
+	c.AddInputPort(INPUT_PORT, formats...)
+	return c
+}
+
+func (c *sink) Run(ctx context.Context) error {
+	c.OnCellStart(ctx)
+	defer c.OnCellFinished()
+
+	for {
+		unit, ok := c.GetInput()
+		if !ok {
+			return nil
+		}
+		if err := c.fn(unit); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/pkg/pipeline"
+)
+
+/* countPackets runs the reader into a sink of the format until the reader finished or ctx is canceled,
+ * fn is called for every unit if not nil, return the number of units received
+ */
+func countPackets(ctx context.Context, t *testing.T, name string, config pipeline.Config, format pipeline.Format, fn pipeline.SinkFunc) (int, error) {
+	p := pipeline.New()
+	reader, err := p.Add(name, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets := 0
+	sink := p.Sink(func(unit pipeline.CellUnit) error {
+		packets++
+		if fn != nil {
+			return fn(unit)
+		}
+		return nil
+	}, format)
+	if err := p.Connect(reader, sink); err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(ctx, pipeline.Options{})
+	return packets, err
+}
+
+func TestHttpReader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, testFile)
+	}))
+	defer server.Close()
+
+	config := pipeline.Config{"url": server.URL + "/data.ts"}
+	packets, err := countPackets(context.Background(), t, "http_reader", config, pipeline.TS_PACKET, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packets != 20 {
+		t.Errorf("packet cnt not match, expected 20, but get %v\n", packets)
+	}
+}
+
+func TestHttpReaderRetry(t *testing.T) {
+	requests := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeFile(w, r, testFile)
+	}))
+	defer server.Close()
+
+	config := pipeline.Config{"url": server.URL, "retries": "1", "retry_delay": "10ms"}
+	packets, err := countPackets(context.Background(), t, "http_reader", config, pipeline.TS_PACKET, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packets != 20 || requests.Load() != 2 {
+		t.Errorf("expected 20 packets in 2 requests, but get %v packets in %v requests\n", packets, requests.Load())
+	}
+
+	// the retries are exhausted
+	requests.Store(0)
+	if _, err := countPackets(context.Background(), t, "http_reader", pipeline.Config{"url": server.URL}, pipeline.TS_PACKET, nil); err == nil {
+		t.Error("expected error for service unavailable")
+	}
+}
+
+func TestTcpReader(t *testing.T) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		// the stream stalls in the middle of a packet and is continued by the next connection
+		stalled, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer stalled.Close()
+		stalled.Write(data[:1000])
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write(data[1000:])
+		conn.Close()
+	}()
+
+	config := pipeline.Config{"addr": listener.Addr().String(), "timeout": "100ms", "retries": "1", "retry_delay": "10ms"}
+	packets, err := countPackets(context.Background(), t, "tcp_reader", config, pipeline.TS_PACKET, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packets != 20 {
+		t.Errorf("packet cnt not match, expected 20, but get %v\n", packets)
+	}
+}
+
+func TestTcpReaderBlockedOutput(t *testing.T) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// separate reads of 5 packets
+		for i := 0; i < len(data); i += 5 * 188 {
+			conn.Write(data[i : i+5*188])
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	// the sink blocks longer than the timeout, the reader is blocked on the full queue but not idle
+	p := pipeline.New()
+	reader, err := p.Add("tcp_reader", pipeline.Config{"addr": listener.Addr().String(), "timeout": "100ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := 0
+	sink := p.Sink(func(unit pipeline.CellUnit) error {
+		if received == 0 {
+			time.Sleep(300 * time.Millisecond)
+		}
+		received += len(unit.Data().([]byte))
+		return nil
+	}, pipeline.BYTE_SLICE)
+	if err := p.ConnectQueue(reader, pipeline.DEFAULT_PORT, sink, pipeline.DEFAULT_PORT, pipeline.EdgeOptions{Capacity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(context.Background(), pipeline.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if received != len(data) {
+		t.Errorf("expected %v bytes, but get %v", len(data), received)
+	}
+}
+
+func TestHlsReader(t *testing.T) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second segment follows a discontinuity, the live playlist ends at the second request
+	media := "#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:7\n#EXTINF:1,\nseg7.ts\n"
+	requests := atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/master.m3u8":
+			w.Write([]byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=500000\nlow/index.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=2000000\nhigh/index.m3u8\n"))
+		case "/low/index.m3u8":
+			if requests.Add(1) == 1 {
+				w.Write([]byte(media))
+			} else {
+				w.Write([]byte(media + "#EXT-X-DISCONTINUITY\n#EXTINF:1,\nseg8.ts\n#EXT-X-ENDLIST\n"))
+			}
+		case "/low/seg7.ts":
+			w.Write(data[:10*188])
+		case "/low/seg8.ts":
+			w.Write(data[10*188:])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	starts := make([]int64, 0)
+	config := pipeline.Config{"url": server.URL + "/master.m3u8", "bandwidth": "1000000"}
+	packets, err := countPackets(context.Background(), t, "hls_reader", config, pipeline.TS_PACKET, func(unit pipeline.CellUnit) error {
+		m := unit.Metadata()
+		if origin, ok := m.Origin.(*pipeline.SegmentOrigin); ok && origin.IsStart(m) {
+			if origin.Segment.Discontinuity != (origin.Segment.Sequence == 8) {
+				t.Errorf("discontinuity of segment %v not match", origin.Segment.Sequence)
+			}
+			starts = append(starts, m.Index)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packets != 20 || len(starts) != 2 || starts[0] != 0 || starts[1] != 10 {
+		t.Errorf("expected 20 packets in segments starting at 0 and 10, but get %v packets in segments starting at %v\n", packets, starts)
+	}
+}
+
+func TestHlsReaderLiveRetry(t *testing.T) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the reload of the live playlist and the second segment fail once
+	media := "#EXTM3U\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:7\n#EXTINF:1,\nseg7.ts\n"
+	requests, segments := atomic.Int32{}, atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.m3u8":
+			switch requests.Add(1) {
+			case 1:
+				w.Write([]byte(media))
+			case 2:
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			case 3:
+				w.Write([]byte(media + "#EXTINF:1,\nseg8.ts\n"))
+			default:
+				w.Write([]byte(media + "#EXTINF:1,\nseg8.ts\n#EXT-X-ENDLIST\n"))
+			}
+		case "/seg7.ts":
+			w.Write(data[:10*188])
+		case "/seg8.ts":
+			if segments.Add(1) == 1 {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data[10*188:])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := pipeline.Config{"url": server.URL + "/index.m3u8", "retry_delay": "10ms"}
+	packets, err := countPackets(context.Background(), t, "hls_reader", config, pipeline.TS_PACKET, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packets != 20 {
+		t.Errorf("expected 20 packets, but get %v", packets)
+	}
+}
+
+func TestPcapReader(t *testing.T) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a pcap of raw ipv4 frames, 4 ts packets in every RTP datagram a millisecond apart
+	start := time.Unix(1700000000, 0)
+	file := binary.LittleEndian.AppendUint32(nil, 0xA1B2C3D4)
+	file = append(file, 2, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0, 0)
+	file = binary.LittleEndian.AppendUint32(file, 101)
+	frame := func(at time.Time, port uint16, sequence uint16, payload []byte) {
+		udp := binary.BigEndian.AppendUint16([]byte{0x0f, 0xa0}, port)
+		udp = binary.BigEndian.AppendUint16(udp, uint16(8+12+len(payload)))
+		udp = append(udp, 0, 0, 0x80, 33)
+		udp = binary.BigEndian.AppendUint16(udp, sequence)
+		udp = append(udp, 0, 0, 0, 0, 0, 0, 0, 1)
+		udp = append(udp, payload...)
+		ip := []byte{0x45, 0, 0, 0, 0, 0, 0x40, 0, 64, 17, 0, 0, 10, 0, 0, 1, 239, 1, 1, 1}
+		binary.BigEndian.PutUint16(ip[2:], uint16(len(ip)+len(udp)))
+		ip = append(ip, udp...)
+		file = binary.LittleEndian.AppendUint32(file, uint32(at.Unix()))
+		file = binary.LittleEndian.AppendUint32(file, uint32(at.Nanosecond()/1000))
+		file = binary.LittleEndian.AppendUint32(file, uint32(len(ip)))
+		file = binary.LittleEndian.AppendUint32(file, uint32(len(ip)))
+		file = append(file, ip...)
+	}
+	for i := 0; i < 5; i++ {
+		at := start.Add(time.Duration(i) * time.Millisecond)
+		payload := data[i*4*188 : (i+1)*4*188]
+		frame(at, 5000, uint16(i), payload)
+		// a duplicate and another stream
+		if i == 2 {
+			frame(at, 5000, uint16(i), payload)
+			frame(at, 5002, uint16(i), payload)
+		}
+	}
+	// a frame cut by the snapshot length, a datagram after a later one,
+	// and the capture cut in the middle of the last record
+	record := len(file)
+	frame(start.Add(5*time.Millisecond), 5000, 5, data[:4*188])
+	binary.LittleEndian.PutUint32(file[record+8:], 100)
+	file = file[:record+16+100]
+	frame(start.Add(5*time.Millisecond), 5000, 7, data[:4*188])
+	frame(start.Add(6*time.Millisecond), 5000, 6, data[:4*188])
+	frame(start.Add(7*time.Millisecond), 5000, 8, data[:4*188])
+	file = file[:len(file)-10]
+	name := filepath.Join(t.TempDir(), "capture.pcap")
+	if err := os.WriteFile(name, file, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := pipeline.Config{"name": name, "filter": "239.1.1.1:5000"}
+	packets, err := countPackets(context.Background(), t, "pcap_reader", config, pipeline.TS_PACKET, func(unit pipeline.CellUnit) error {
+		m := unit.Metadata()
+		expected := start.Add(time.Duration(m.Index/4) * time.Millisecond)
+		origin, _ := m.Origin.(*pipeline.DatagramOrigin)
+		if !m.Received.Equal(expected) || m.Monotonic != expected.Sub(start) || origin == nil || origin.Rtp == nil || origin.Sender != "10.0.0.1:4000" {
+			t.Errorf("metadata of packet %v not match: %v %v %+v", m.Index, m.Received, m.Monotonic, origin)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the late datagram is dropped
+	if packets != 24 {
+		t.Errorf("packet cnt not match, expected 24, but get %v\n", packets)
+	}
+}
+
+func TestUdpReader(t *testing.T) {
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		network string
+		address string
+		rtp     bool
+	}{
+		{"udp4", "127.0.0.1:0", true},
+		{"udp4", "127.0.0.1:0", false},
+		{"udp6", "[::1]:0", true},
+	}
+	for _, c := range cases {
+		rtp := c.rtp
+		// a free port for the reader
+		conn, err := net.ListenPacket(c.network, c.address)
+		if err != nil {
+			if c.network == "udp6" {
+				t.Logf("ipv6 loopback not available: %v", err)
+				continue
+			}
+			t.Fatal(err)
+		}
+		addr := conn.LocalAddr().String()
+		conn.Close()
+		sender, err := net.Dial(c.network, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sender.Close()
+
+		// 4 ts packets in every datagram, the rtp header is stripped by the reader
+		datagrams := make([][]byte, 0)
+		for i := 0; i < len(data); i += 4 * 188 {
+			datagram := data[i:min(i+4*188, len(data))]
+			if rtp {
+				header := binary.BigEndian.AppendUint16([]byte{0x80, 33}, uint16(len(datagrams)))
+				datagram = append(append(header, 0, 0, 0, 0, 0, 0, 0, 1), datagram...)
+			}
+			datagrams = append(datagrams, datagram)
+		}
+		listening := make(chan struct{})
+		go func() {
+			// the first datagram is resent until the reader is listening
+			for sent := false; !sent; {
+				sender.Write(datagrams[0])
+				select {
+				case <-listening:
+					sent = true
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+			for _, datagram := range datagrams[1:] {
+				sender.Write(datagram)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		received := make([]byte, 0)
+		_, err = countPackets(ctx, t, "udp_reader", pipeline.Config{"addr": addr}, pipeline.BYTE_SLICE, func(unit pipeline.CellUnit) error {
+			payload := unit.Data().([]byte)
+			if len(received) == 0 {
+				close(listening)
+			}
+			if origin, ok := unit.Metadata().Origin.(*pipeline.DatagramOrigin); !ok || origin.Sender != sender.LocalAddr().String() || (origin.Rtp != nil) != rtp {
+				t.Errorf("origin not match: %+v", unit.Metadata().Origin)
+			}
+			received = append(received, payload...)
+			if len(received) >= len(data) {
+				cancel()
+			}
+			return nil
+		})
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the raw copies of the first datagram are not detected as duplicates
+		first := data[:4*188]
+		for !rtp && len(received) > len(data) && bytes.HasPrefix(received[len(first):], first) {
+			received = received[len(first):]
+		}
+		if !bytes.Equal(received, data) {
+			t.Errorf("payload not match from %v with rtp %v, expected %v bytes, but get %v bytes", addr, rtp, len(data), len(received))
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"github.com/potterxu/tsanalyzer/internal/cell"
+	"github.com/potterxu/tsanalyzer/internal/cell/icell"
+	"github.com/potterxu/tsanalyzer/internal/cell/impl/reader"
+	"github.com/potterxu/tsanalyzer/internal/errinfo"
+	"github.com/potterxu/tsanalyzer/internal/graph"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
+)
+
+// Types shared with the cell engine, custom cells should composite Cell
+// and implement Run, see icell.Cell for the helper methods
+type (
+	Cell      = icell.Cell
+	ICell     = icell.ICell
+	Config    = icell.Config
+	Format    = icell.Format
+	CellUnit  = icell.CellUnit
+	Metadata  = icell.Metadata
+	Options   = graph.Options
+	CellError = errinfo.CellError
+	Checker   = icell.Checker // optional, verifies the resources of a cell for pipe --check
+)
+
+// Data specific to the readers in Metadata.Origin
+type (
+	DatagramOrigin = reader.DatagramOrigin
+	StreamOrigin   = reader.StreamOrigin
+	SegmentOrigin  = reader.SegmentOrigin
+)
+
+// Queue of a connection, see Pipeline.ConnectQueue
+type (
+	EdgeOptions = icell.EdgeOptions
+	EdgePolicy  = icell.EdgePolicy
+)
+
+const (
+	POLICY_BLOCK       EdgePolicy = icell.POLICY_BLOCK
+	POLICY_DROP_NEWEST EdgePolicy = icell.POLICY_DROP_NEWEST
+	POLICY_DROP_OLDEST EdgePolicy = icell.POLICY_DROP_OLDEST
+)
+
+// Runtime metrics, see Pipeline.Stats
+type (
+	Stats     = graph.Stats
+	CellStats = graph.CellStats
+	EdgeStats = graph.EdgeStats
+)
+
+// Registration of cells, see Register
+type (
+	Constructor  = cell.Constructor
+	CellType     = cell.CellType
+	Registration = cell.Registration
+)
+
+// Cell description and typed properties, see ParseConfig
+type (
+	Spec         = icell.Spec
+	PortSpec     = icell.PortSpec
+	Schema       = icell.Schema
+	Property     = icell.Property
+	PropertyType = icell.PropertyType
+	Properties   = icell.Properties
+)
+
+// Property types
+const (
+	PROP_STRING   PropertyType = icell.PROP_STRING
+	PROP_INT      PropertyType = icell.PROP_INT
+	PROP_UINT     PropertyType = icell.PROP_UINT
+	PROP_BOOL     PropertyType = icell.PROP_BOOL
+	PROP_INT_LIST PropertyType = icell.PROP_INT_LIST
+)
+
+// Cell types shown in the help
+const (
+	READER    CellType = cell.TYPE_READER
+	CONVERTER CellType = cell.TYPE_CONVERTER
+	PROCESSOR CellType = cell.TYPE_PROCESSOR
+	WRITER    CellType = cell.TYPE_WRITER
+)
+
+// Formats of the units passed between cells
+const (
+	BYTE_SLICE Format = icell.BYTE_SLICE
+	STRING     Format = icell.STRING
+	TS_PACKET  Format = icell.TS_PACKET
+	TS_PACKETS Format = icell.TS_PACKETS
+	PES_PACKET Format = icell.PES_PACKET
+	SECTION    Format = icell.SECTION
+)
+
+// Data of the PES_PACKET and SECTION units
+type (
+	PesPacket = ts.PesPacket
+	Section   = ts.Section
+)
+
+// Port names
+const (
+	DEFAULT_PORT = icell.DEFAULT_PORT
+	INPUT_PORT   = icell.INPUT_PORT
+	OUTPUT_PORT  = icell.OUTPUT_PORT
+)
+
+// UNKNOWN marks an unknown offset or index in the metadata
+const UNKNOWN = icell.UNKNOWN
+
+func NewCellUnit(data interface{}, format Format) CellUnit {
+	return icell.NewCellUnit(data, format)
+}
+
+// NewCellUnitWithMetadata returns a unit carrying where and when its data was received
+func NewCellUnitWithMetadata(data interface{}, format Format, metadata *Metadata) CellUnit {
+	return icell.NewCellUnitWithMetadata(data, format, metadata)
+}
+
+// NewMetadata returns the metadata of data received now by the source cell
+func NewMetadata(source string) *Metadata {
+	return icell.NewMetadata(source)
+}
+
+// ParseConfig validates the config of a cell against its schema
+// and returns the typed properties with defaults applied
+func ParseConfig(name string, s Schema, config Config) (Properties, error) {
+	return icell.ParseConfig(name, s, config)
+}
+
+// Release tells that the cell consumed the unit and does not forward it,
+// so the buffers of readers such as file_reader can be reused
+func Release(unit CellUnit) {
+	icell.Release(unit)
+}
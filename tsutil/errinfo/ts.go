@@ -5,4 +5,28 @@ import "errors"
 var (
 	// ErrNilFinish is returned when the finish function is nil
 	ErrNilFinish = errors.New("finish function is nil")
+
+	// ErrInvalidPes is returned when the accumulated data is not a complete pes packet
+	ErrInvalidPes = errors.New("invalid pes packet")
+
+	// ErrInvalidSection is returned when the assembled data is not a valid section
+	ErrInvalidSection = errors.New("invalid section")
+
+	// ErrCrc is returned when the CRC_32 of a section does not match its data
+	ErrCrc = errors.New("CRC_error")
+
+	// ErrInvalidRtp is returned when a datagram is not a valid rtp packet
+	ErrInvalidRtp = errors.New("invalid rtp packet")
+
+	// ErrInvalidPlaylist is returned when a m3u8 playlist cannot be parsed or is not supported
+	ErrInvalidPlaylist = errors.New("invalid playlist")
+
+	// ErrInvalidCapture is returned when a file is not a valid pcap or pcapng capture
+	ErrInvalidCapture = errors.New("invalid capture")
+
+	// ErrInvalidFrame is returned when a captured frame is truncated or its headers are invalid
+	ErrInvalidFrame = errors.New("invalid frame")
+
+	// ErrUnsupportedFrame is returned when a captured frame cannot be decoded, e.g. ip fragments or an unknown link type
+	ErrUnsupportedFrame = errors.New("unsupported frame")
 )
@@ -0,0 +1,189 @@
+package hls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+)
+
+// tags of RFC 8216 4.3
+const (
+	TAG_HEADER                 = "#EXTM3U"
+	TAG_SEGMENT                = "#EXTINF:"
+	TAG_TARGET_DURATION        = "#EXT-X-TARGETDURATION:"
+	TAG_MEDIA_SEQUENCE         = "#EXT-X-MEDIA-SEQUENCE:"
+	TAG_DISCONTINUITY_SEQUENCE = "#EXT-X-DISCONTINUITY-SEQUENCE:"
+	TAG_DISCONTINUITY          = "#EXT-X-DISCONTINUITY"
+	TAG_ENDLIST                = "#EXT-X-ENDLIST"
+	TAG_STREAM_INF             = "#EXT-X-STREAM-INF:"
+	TAG_KEY                    = "#EXT-X-KEY:"
+	TAG_MAP                    = "#EXT-X-MAP:"
+	TAG_BYTERANGE              = "#EXT-X-BYTERANGE:"
+)
+
+/* Variant is a stream of a master playlist
+ * URI: the media playlist, relative to the master playlist
+ * Bandwidth: peak bits per second, BANDWIDTH
+ * Resolution, Codecs: RESOLUTION and CODECS, empty if not present
+ */
+type Variant struct {
+	URI        string
+	Bandwidth  uint64
+	Resolution string
+	Codecs     string
+}
+
+/* Segment is a media segment of a media playlist
+ * URI: the segment, relative to the media playlist
+ * Duration: duration of the segment, EXTINF
+ * Sequence: media sequence number of the segment
+ * Discontinuity: EXT-X-DISCONTINUITY precedes the segment, e.g. timestamps or continuity counters restart
+ * DiscontinuitySequence: discontinuity sequence number of the segment
+ */
+type Segment struct {
+	URI                   string
+	Duration              time.Duration
+	Sequence              int64
+	Discontinuity         bool
+	DiscontinuitySequence int64
+}
+
+/* Playlist is a master or a media playlist
+ * Variants: the streams of a master playlist, empty for a media playlist
+ * TargetDuration: the maximum segment duration, EXT-X-TARGETDURATION
+ * Segments: the segments in order
+ * Ended: no segments will be added, EXT-X-ENDLIST. A live playlist is reloaded until it ends
+ */
+type Playlist struct {
+	Variants       []Variant
+	TargetDuration time.Duration
+	Segments       []Segment
+	Ended          bool
+}
+
+func (p *Playlist) IsMaster() bool {
+	return len(p.Variants) > 0
+}
+
+/* Parse the m3u8 playlist
+ * return errinfo.ErrInvalidPlaylist if it is not a playlist,
+ * or if it uses encryption, byte ranges or fragmented mp4 which are not supported
+ */
+func Parse(r io.Reader) (*Playlist, error) {
+	p := &Playlist{}
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF")) != TAG_HEADER {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: no %v header", errinfo.ErrInvalidPlaylist, TAG_HEADER)
+	}
+
+	sequence, discontinuitySequence := int64(0), int64(0)
+	// the tags applying to the next uri
+	var segment *Segment
+	var variant *Variant
+	discontinuity := false
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		var err error
+		switch {
+		case text == "":
+		case strings.HasPrefix(text, TAG_SEGMENT):
+			segment = &Segment{}
+			duration := strings.SplitN(strings.TrimPrefix(text, TAG_SEGMENT), ",", 2)[0]
+			segment.Duration, err = parseDuration(duration)
+		case strings.HasPrefix(text, TAG_TARGET_DURATION):
+			p.TargetDuration, err = parseDuration(strings.TrimPrefix(text, TAG_TARGET_DURATION))
+		case strings.HasPrefix(text, TAG_MEDIA_SEQUENCE):
+			sequence, err = strconv.ParseInt(strings.TrimPrefix(text, TAG_MEDIA_SEQUENCE), 10, 64)
+		case strings.HasPrefix(text, TAG_DISCONTINUITY_SEQUENCE):
+			discontinuitySequence, err = strconv.ParseInt(strings.TrimPrefix(text, TAG_DISCONTINUITY_SEQUENCE), 10, 64)
+		case text == TAG_DISCONTINUITY:
+			discontinuity = true
+		case text == TAG_ENDLIST:
+			p.Ended = true
+		case strings.HasPrefix(text, TAG_STREAM_INF):
+			attributes := ParseAttributes(strings.TrimPrefix(text, TAG_STREAM_INF))
+			variant = &Variant{Resolution: attributes["RESOLUTION"], Codecs: attributes["CODECS"]}
+			variant.Bandwidth, err = strconv.ParseUint(attributes["BANDWIDTH"], 10, 64)
+		case strings.HasPrefix(text, TAG_KEY):
+			if method := ParseAttributes(strings.TrimPrefix(text, TAG_KEY))["METHOD"]; method != "NONE" {
+				err = fmt.Errorf("encryption %v is not supported", method)
+			}
+		case strings.HasPrefix(text, TAG_MAP), strings.HasPrefix(text, TAG_BYTERANGE):
+			err = fmt.Errorf("%v is not supported", strings.SplitN(text, ":", 2)[0])
+		case strings.HasPrefix(text, "#"):
+			// comments and tags not needed to read the segments
+		case variant != nil:
+			variant.URI = text
+			p.Variants = append(p.Variants, *variant)
+			variant = nil
+		case segment != nil:
+			if discontinuity {
+				discontinuitySequence++
+			}
+			segment.URI = text
+			segment.Sequence = sequence
+			segment.Discontinuity = discontinuity
+			segment.DiscontinuitySequence = discontinuitySequence
+			p.Segments = append(p.Segments, *segment)
+			sequence++
+			segment = nil
+			discontinuity = false
+		default:
+			err = fmt.Errorf("uri %v without %v or %v", text, TAG_SEGMENT, TAG_STREAM_INF)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %v: %v", errinfo.ErrInvalidPlaylist, line+1, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !p.IsMaster() && p.TargetDuration == 0 {
+		return nil, fmt.Errorf("%w: no %v", errinfo.ErrInvalidPlaylist, TAG_TARGET_DURATION)
+	}
+	return p, nil
+}
+
+// parseDuration parses a duration in decimal seconds
+func parseDuration(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid duration %v", s)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ParseAttributes parses an attribute list of RFC 8216 4.2, the quotes of quoted strings are removed
+func ParseAttributes(s string) map[string]string {
+	attributes := make(map[string]string)
+	for len(s) > 0 {
+		name, rest, found := strings.Cut(s, "=")
+		if !found {
+			break
+		}
+		value := ""
+		if strings.HasPrefix(rest, "\"") {
+			// quoted strings may contain commas
+			end := strings.Index(rest[1:], "\"")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			value = rest[1 : end+1]
+			rest = rest[min(end+2, len(rest)):]
+			_, rest, _ = strings.Cut(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attributes[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		s = rest
+	}
+	return attributes
+}
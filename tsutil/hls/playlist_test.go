@@ -0,0 +1,80 @@
+package hls_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+	"github.com/potterxu/tsanalyzer/tsutil/hls"
+)
+
+func TestParseMaster(t *testing.T) {
+	p, err := hls.Parse(strings.NewReader(`#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=640x360
+low/index.m3u8
+#EXT-X-STREAM-INF:RESOLUTION=1920x1080,BANDWIDTH=5000000
+high/index.m3u8
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []hls.Variant{
+		{URI: "low/index.m3u8", Bandwidth: 1280000, Resolution: "640x360", Codecs: "avc1.4d401f,mp4a.40.2"},
+		{URI: "high/index.m3u8", Bandwidth: 5000000, Resolution: "1920x1080"},
+	}
+	if !p.IsMaster() || len(p.Variants) != len(expected) {
+		t.Fatalf("variants not match: %+v", p.Variants)
+	}
+	for i := range expected {
+		if p.Variants[i] != expected[i] {
+			t.Errorf("variant %v not match, expected %+v, but get %+v", i, expected[i], p.Variants[i])
+		}
+	}
+}
+
+func TestParseMedia(t *testing.T) {
+	p, err := hls.Parse(strings.NewReader(`#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-DISCONTINUITY-SEQUENCE:2
+#EXTINF:6.006,
+seg100.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:5.5,ad
+http://ads/seg0.ts
+#EXT-X-ENDLIST
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []hls.Segment{
+		{URI: "seg100.ts", Duration: 6006 * time.Millisecond, Sequence: 100, DiscontinuitySequence: 2},
+		{URI: "http://ads/seg0.ts", Duration: 5500 * time.Millisecond, Sequence: 101, Discontinuity: true, DiscontinuitySequence: 3},
+	}
+	if p.IsMaster() || !p.Ended || p.TargetDuration != 6*time.Second || len(p.Segments) != len(expected) {
+		t.Fatalf("playlist not match: %+v", p)
+	}
+	for i := range expected {
+		if p.Segments[i] != expected[i] {
+			t.Errorf("segment %v not match, expected %+v, but get %+v", i, expected[i], p.Segments[i])
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := []string{
+		"seg0.ts\n",
+		"#EXTM3U\n#EXTINF:6,\nseg0.ts\n",
+		"#EXTM3U\n#EXT-X-TARGETDURATION:6\nseg0.ts\n",
+		"#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXT-X-KEY:METHOD=AES-128,URI=\"key\"\n#EXTINF:6,\nseg0.ts\n",
+		"#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:6,\nseg0.m4s\n",
+	}
+	for i, c := range cases {
+		if _, err := hls.Parse(strings.NewReader(c)); !errors.Is(err, errinfo.ErrInvalidPlaylist) {
+			t.Errorf("case %v: expected invalid playlist, but get %v", i, err)
+		}
+	}
+}
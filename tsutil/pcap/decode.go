@@ -0,0 +1,179 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+)
+
+const (
+	// link types of the captured frames
+	LINKTYPE_ETHERNET   = 1
+	LINKTYPE_RAW        = 101 // ipv4 or ipv6 without link header
+	LINKTYPE_LINUX_SLL  = 113 // linux cooked capture, e.g. tcpdump -i any
+	LINKTYPE_IPV4       = 228
+	LINKTYPE_IPV6       = 229
+	LINKTYPE_LINUX_SLL2 = 276
+
+	ETHERNET_HEADER_LENGTH = 14
+	VLAN_TAG_LENGTH        = 4
+	SLL_HEADER_LENGTH      = 16
+	SLL2_HEADER_LENGTH     = 20
+	IPV4_HEADER_LENGTH     = 20
+	IPV6_HEADER_LENGTH     = 40
+	UDP_HEADER_LENGTH      = 8
+
+	ETHERTYPE_IPV4  = 0x0800
+	ETHERTYPE_IPV6  = 0x86DD
+	ETHERTYPE_VLAN  = 0x8100 // 802.1Q
+	ETHERTYPE_QINQ  = 0x88A8 // 802.1ad
+	ETHERTYPE_QINQ1 = 0x9100 // legacy 802.1ad
+
+	PROTOCOL_UDP = 17
+
+	// ipv6 extension headers
+	IPV6_HOP_BY_HOP  = 0
+	IPV6_ROUTING     = 43
+	IPV6_FRAGMENT    = 44
+	IPV6_DESTINATION = 60
+)
+
+/* Datagram is an udp datagram decoded from a frame
+ * Src, Dst: the source and destination addresses and ports
+ * Payload: the udp payload, a slice of the frame
+ */
+type Datagram struct {
+	Src     netip.AddrPort
+	Dst     netip.AddrPort
+	Payload []byte
+}
+
+/* DecodeUdp decodes the udp datagram carried by the frame of the link type
+ * return nil if the frame is not an udp datagram, e.g. arp or tcp
+ * return errinfo.ErrInvalidFrame if the frame is truncated or its headers are invalid
+ * return errinfo.ErrUnsupportedFrame for ip fragments, which are not reassembled, or an unknown link type
+ */
+func DecodeUdp(linkType uint16, frame []byte) (*Datagram, error) {
+	switch linkType {
+	case LINKTYPE_ETHERNET:
+		if len(frame) < ETHERNET_HEADER_LENGTH {
+			return nil, fmt.Errorf("%w: ethernet frame of %v bytes", errinfo.ErrInvalidFrame, len(frame))
+		}
+		etherType := binary.BigEndian.Uint16(frame[12:])
+		frame = frame[ETHERNET_HEADER_LENGTH:]
+		// the vlan tags precede the ethertype of the payload
+		for etherType == ETHERTYPE_VLAN || etherType == ETHERTYPE_QINQ || etherType == ETHERTYPE_QINQ1 {
+			if len(frame) < VLAN_TAG_LENGTH {
+				return nil, fmt.Errorf("%w: truncated vlan tag", errinfo.ErrInvalidFrame)
+			}
+			etherType = binary.BigEndian.Uint16(frame[2:])
+			frame = frame[VLAN_TAG_LENGTH:]
+		}
+		return decodeIp(etherType, frame)
+	case LINKTYPE_LINUX_SLL:
+		if len(frame) < SLL_HEADER_LENGTH {
+			return nil, fmt.Errorf("%w: linux cooked frame of %v bytes", errinfo.ErrInvalidFrame, len(frame))
+		}
+		return decodeIp(binary.BigEndian.Uint16(frame[14:]), frame[SLL_HEADER_LENGTH:])
+	case LINKTYPE_LINUX_SLL2:
+		if len(frame) < SLL2_HEADER_LENGTH {
+			return nil, fmt.Errorf("%w: linux cooked frame of %v bytes", errinfo.ErrInvalidFrame, len(frame))
+		}
+		return decodeIp(binary.BigEndian.Uint16(frame), frame[SLL2_HEADER_LENGTH:])
+	case LINKTYPE_RAW:
+		if len(frame) == 0 {
+			return nil, fmt.Errorf("%w: empty frame", errinfo.ErrInvalidFrame)
+		}
+		switch frame[0] >> 4 {
+		case 4:
+			return decodeIpv4(frame)
+		case 6:
+			return decodeIpv6(frame)
+		}
+		return nil, fmt.Errorf("%w: ip version %v", errinfo.ErrInvalidFrame, frame[0]>>4)
+	case LINKTYPE_IPV4:
+		return decodeIpv4(frame)
+	case LINKTYPE_IPV6:
+		return decodeIpv6(frame)
+	}
+	return nil, fmt.Errorf("%w: link type %v", errinfo.ErrUnsupportedFrame, linkType)
+}
+
+func decodeIp(etherType uint16, packet []byte) (*Datagram, error) {
+	switch etherType {
+	case ETHERTYPE_IPV4:
+		return decodeIpv4(packet)
+	case ETHERTYPE_IPV6:
+		return decodeIpv6(packet)
+	}
+	return nil, nil
+}
+
+func decodeIpv4(packet []byte) (*Datagram, error) {
+	if len(packet) < IPV4_HEADER_LENGTH || packet[0]>>4 != 4 {
+		return nil, fmt.Errorf("%w: ipv4 header", errinfo.ErrInvalidFrame)
+	}
+	headerLength := 4 * int(packet[0]&0x0F)
+	totalLength := int(binary.BigEndian.Uint16(packet[2:]))
+	if headerLength < IPV4_HEADER_LENGTH || totalLength < headerLength || totalLength > len(packet) {
+		return nil, fmt.Errorf("%w: ipv4 packet of %v bytes, header of %v bytes in %v bytes", errinfo.ErrInvalidFrame, totalLength, headerLength, len(packet))
+	}
+	if packet[9] != PROTOCOL_UDP {
+		return nil, nil
+	}
+	// more fragments or a fragment offset
+	if binary.BigEndian.Uint16(packet[6:])&0x3FFF != 0 {
+		return nil, fmt.Errorf("%w: ipv4 fragment", errinfo.ErrUnsupportedFrame)
+	}
+	src, _ := netip.AddrFromSlice(packet[12:16])
+	dst, _ := netip.AddrFromSlice(packet[16:20])
+	// the ethernet padding follows the ip packet
+	return decodeUdp(src, dst, packet[headerLength:totalLength])
+}
+
+func decodeIpv6(packet []byte) (*Datagram, error) {
+	if len(packet) < IPV6_HEADER_LENGTH || packet[0]>>4 != 6 {
+		return nil, fmt.Errorf("%w: ipv6 header", errinfo.ErrInvalidFrame)
+	}
+	payloadLength := int(binary.BigEndian.Uint16(packet[4:]))
+	if IPV6_HEADER_LENGTH+payloadLength > len(packet) {
+		return nil, fmt.Errorf("%w: ipv6 payload of %v bytes in %v bytes", errinfo.ErrInvalidFrame, payloadLength, len(packet))
+	}
+	src, _ := netip.AddrFromSlice(packet[8:24])
+	dst, _ := netip.AddrFromSlice(packet[24:40])
+	next := packet[6]
+	payload := packet[IPV6_HEADER_LENGTH : IPV6_HEADER_LENGTH+payloadLength]
+	for {
+		switch next {
+		case PROTOCOL_UDP:
+			return decodeUdp(src, dst, payload)
+		case IPV6_FRAGMENT:
+			return nil, fmt.Errorf("%w: ipv6 fragment", errinfo.ErrUnsupportedFrame)
+		case IPV6_HOP_BY_HOP, IPV6_ROUTING, IPV6_DESTINATION:
+			if len(payload) < 8 || len(payload) < 8*(int(payload[1])+1) {
+				return nil, fmt.Errorf("%w: ipv6 extension header", errinfo.ErrInvalidFrame)
+			}
+			next = payload[0]
+			payload = payload[8*(int(payload[1])+1):]
+		default:
+			return nil, nil
+		}
+	}
+}
+
+func decodeUdp(src netip.Addr, dst netip.Addr, segment []byte) (*Datagram, error) {
+	if len(segment) < UDP_HEADER_LENGTH {
+		return nil, fmt.Errorf("%w: udp header", errinfo.ErrInvalidFrame)
+	}
+	length := int(binary.BigEndian.Uint16(segment[4:]))
+	if length < UDP_HEADER_LENGTH || length > len(segment) {
+		return nil, fmt.Errorf("%w: udp datagram of %v bytes in %v bytes", errinfo.ErrInvalidFrame, length, len(segment))
+	}
+	return &Datagram{
+		Src:     netip.AddrPortFrom(src, binary.BigEndian.Uint16(segment)),
+		Dst:     netip.AddrPortFrom(dst, binary.BigEndian.Uint16(segment[2:])),
+		Payload: segment[UDP_HEADER_LENGTH:length],
+	}, nil
+}
@@ -0,0 +1,186 @@
+package pcap_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+	"github.com/potterxu/tsanalyzer/tsutil/pcap"
+)
+
+var payload = bytes.Repeat([]byte{0x47, 0x1f, 0xff, 0x10}, 47)
+
+// udpPacket returns an ipv4 or ipv6 packet carrying an udp datagram of the payload
+func udpPacket(src netip.AddrPort, dst netip.AddrPort, payload []byte) []byte {
+	udp := binary.BigEndian.AppendUint16(nil, src.Port())
+	udp = binary.BigEndian.AppendUint16(udp, dst.Port())
+	udp = binary.BigEndian.AppendUint16(udp, uint16(pcap.UDP_HEADER_LENGTH+len(payload)))
+	udp = append(udp, 0, 0)
+	udp = append(udp, payload...)
+	if src.Addr().Is4() {
+		ip := []byte{0x45, 0, 0, 0, 0, 0, 0x40, 0, 64, pcap.PROTOCOL_UDP, 0, 0}
+		binary.BigEndian.PutUint16(ip[2:], uint16(pcap.IPV4_HEADER_LENGTH+len(udp)))
+		ip = append(ip, src.Addr().AsSlice()...)
+		ip = append(ip, dst.Addr().AsSlice()...)
+		return append(ip, udp...)
+	}
+	ip := []byte{0x60, 0, 0, 0, 0, 0, pcap.PROTOCOL_UDP, 64}
+	binary.BigEndian.PutUint16(ip[4:], uint16(len(udp)))
+	ip = append(ip, src.Addr().AsSlice()...)
+	ip = append(ip, dst.Addr().AsSlice()...)
+	return append(ip, udp...)
+}
+
+// ethernetFrame returns the ethernet frame of the ip packet in the vlans
+func ethernetFrame(packet []byte, vlans ...uint16) []byte {
+	frame := make([]byte, 12)
+	for _, vlan := range vlans {
+		frame = binary.BigEndian.AppendUint16(frame, pcap.ETHERTYPE_VLAN)
+		frame = binary.BigEndian.AppendUint16(frame, vlan)
+	}
+	etherType := uint16(pcap.ETHERTYPE_IPV4)
+	if packet[0]>>4 == 6 {
+		etherType = pcap.ETHERTYPE_IPV6
+	}
+	frame = binary.BigEndian.AppendUint16(frame, etherType)
+	return append(frame, packet...)
+}
+
+func TestDecodeUdp(t *testing.T) {
+	src4, dst4 := netip.MustParseAddrPort("10.0.0.1:4000"), netip.MustParseAddrPort("239.1.1.1:5000")
+	src6, dst6 := netip.MustParseAddrPort("[2001:db8::1]:4000"), netip.MustParseAddrPort("[ff3e::1]:5000")
+	cases := []struct {
+		linkType uint16
+		frame    []byte
+		src, dst netip.AddrPort
+	}{
+		{pcap.LINKTYPE_ETHERNET, ethernetFrame(udpPacket(src4, dst4, payload)), src4, dst4},
+		// ethernet padding after the ip packet
+		{pcap.LINKTYPE_ETHERNET, append(ethernetFrame(udpPacket(src4, dst4, payload), 100, 200), 0, 0, 0, 0), src4, dst4},
+		{pcap.LINKTYPE_ETHERNET, ethernetFrame(udpPacket(src6, dst6, payload), 100), src6, dst6},
+		{pcap.LINKTYPE_RAW, udpPacket(src6, dst6, payload), src6, dst6},
+	}
+	for i, c := range cases {
+		d, err := pcap.DecodeUdp(c.linkType, c.frame)
+		if err != nil {
+			t.Fatalf("case %v: %v", i, err)
+		}
+		if d.Src != c.src || d.Dst != c.dst || !bytes.Equal(d.Payload, payload) {
+			t.Errorf("case %v: datagram not match: %v -> %v, %v bytes", i, d.Src, d.Dst, len(d.Payload))
+		}
+	}
+
+	fragment := udpPacket(src4, dst4, payload)
+	fragment[6] = 0x20
+	if _, err := pcap.DecodeUdp(pcap.LINKTYPE_RAW, fragment); !errors.Is(err, errinfo.ErrUnsupportedFrame) {
+		t.Errorf("expected unsupported fragment, but get %v", err)
+	}
+	truncated := ethernetFrame(udpPacket(src4, dst4, payload))
+	if _, err := pcap.DecodeUdp(pcap.LINKTYPE_ETHERNET, truncated[:100]); !errors.Is(err, errinfo.ErrInvalidFrame) {
+		t.Errorf("expected invalid frame, but get %v", err)
+	}
+	arp := append(make([]byte, 12), 0x08, 0x06)
+	if d, err := pcap.DecodeUdp(pcap.LINKTYPE_ETHERNET, append(arp, make([]byte, 28)...)); d != nil || err != nil {
+		t.Errorf("expected not udp, but get %v %v", d, err)
+	}
+}
+
+func TestReaderPcap(t *testing.T) {
+	frame := ethernetFrame(udpPacket(netip.MustParseAddrPort("10.0.0.1:4000"), netip.MustParseAddrPort("239.1.1.1:5000"), payload))
+	// big endian with nanoseconds
+	order := binary.BigEndian
+	file := order.AppendUint32(nil, pcap.MAGIC_NANOSECONDS)
+	file = append(file, 0, 2, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff)
+	file = order.AppendUint32(file, pcap.LINKTYPE_ETHERNET)
+	for i := 0; i < 2; i++ {
+		file = order.AppendUint32(file, 1700000000)
+		file = order.AppendUint32(file, uint32(i*1000+1))
+		file = order.AppendUint32(file, uint32(len(frame)))
+		file = order.AppendUint32(file, uint32(len(frame)))
+		file = append(file, frame...)
+	}
+
+	r, err := pcap.NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		p, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := time.Unix(1700000000, int64(i*1000+1))
+		if !p.Timestamp.Equal(expected) || p.LinkType != pcap.LINKTYPE_ETHERNET || !bytes.Equal(p.Data, frame) {
+			t.Errorf("packet %v not match: %v %v %v bytes", i, p.Timestamp, p.LinkType, len(p.Data))
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF, but get %v", err)
+	}
+
+	// cut in the middle of a record
+	r, _ = pcap.NewReader(bytes.NewReader(file[:len(file)-10]))
+	r.Next()
+	if _, err := r.Next(); !errors.Is(err, errinfo.ErrInvalidCapture) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected truncated capture, but get %v", err)
+	}
+}
+
+// block returns a little endian pcapng block of the body, padded to 32 bits
+func block(blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	length := uint32(pcap.BLOCK_HEADER_LENGTH + len(body))
+	b := binary.LittleEndian.AppendUint32(nil, blockType)
+	b = binary.LittleEndian.AppendUint32(b, length)
+	b = append(b, body...)
+	return binary.LittleEndian.AppendUint32(b, length)
+}
+
+func TestReaderPcapng(t *testing.T) {
+	frame := udpPacket(netip.MustParseAddrPort("[2001:db8::1]:4000"), netip.MustParseAddrPort("[ff3e::1]:5000"), payload)
+	section := binary.LittleEndian.AppendUint32(nil, pcap.BYTE_ORDER_MAGIC)
+	section = append(section, 1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	// raw ip interfaces with timestamps in nanoseconds and in 2^-20 seconds
+	nano := []byte{pcap.LINKTYPE_RAW, 0, 0, 0, 0, 0, 0, 0, pcap.OPTION_IF_TSRESOL, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0, 0}
+	power2 := []byte{pcap.LINKTYPE_RAW, 0, 0, 0, 0, 0, 0, 0, pcap.OPTION_IF_TSRESOL, 0, 1, 0, 0x80 | 20, 0, 0, 0}
+	packet := func(id uint32, units uint64) []byte {
+		body := binary.LittleEndian.AppendUint32(nil, id)
+		body = binary.LittleEndian.AppendUint32(body, uint32(units>>32))
+		body = binary.LittleEndian.AppendUint32(body, uint32(units))
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(frame)))
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(frame)))
+		return block(pcap.BLOCK_ENHANCED_PACKET, append(body, frame...))
+	}
+	file := block(pcap.BLOCK_SECTION_HEADER, section)
+	file = append(file, block(pcap.BLOCK_INTERFACE_DESCRIPTION, nano)...)
+	file = append(file, block(pcap.BLOCK_INTERFACE_DESCRIPTION, power2)...)
+	// name resolution block
+	file = append(file, block(4, []byte{0, 0, 0, 0})...)
+	file = append(file, packet(0, 1700000000_000000123)...)
+	file = append(file, packet(1, 1700000000<<20|1<<19)...)
+
+	r, err := pcap.NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Time{time.Unix(1700000000, 123), time.Unix(1700000000, 500000000)}
+	for i := range expected {
+		p, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Timestamp.Equal(expected[i]) || p.LinkType != pcap.LINKTYPE_RAW || !bytes.Equal(p.Data, frame) {
+			t.Errorf("packet %v not match: %v %v %v bytes", i, p.Timestamp, p.LinkType, len(p.Data))
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF, but get %v", err)
+	}
+}
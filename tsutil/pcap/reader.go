@@ -0,0 +1,297 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"time"
+
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+)
+
+const (
+	// pcap file header magic numbers, written in the byte order of the capturing host
+	MAGIC_MICROSECONDS = 0xA1B2C3D4
+	MAGIC_NANOSECONDS  = 0xA1B23C4D
+
+	PCAP_HEADER_LENGTH        = 24
+	PCAP_RECORD_HEADER_LENGTH = 16
+
+	// pcapng block types
+	BLOCK_SECTION_HEADER        = 0x0A0D0D0A
+	BLOCK_INTERFACE_DESCRIPTION = 0x00000001
+	BLOCK_SIMPLE_PACKET         = 0x00000003
+	BLOCK_ENHANCED_PACKET       = 0x00000006
+
+	BYTE_ORDER_MAGIC    = 0x1A2B3C4D
+	BLOCK_HEADER_LENGTH = 12 // type, total length and the trailing total length
+
+	OPTION_END_OF_OPT  = 0
+	OPTION_IF_TSRESOL  = 9
+	OPTION_IF_TSOFFSET = 14
+
+	// larger records or blocks are considered corrupted, the largest snapshot length of tcpdump
+	MAX_PACKET_LENGTH = 262144
+	MAX_BLOCK_LENGTH  = 1 << 24
+)
+
+/* Packet is a frame of a capture
+ * Timestamp: when the frame was captured, zero if not recorded
+ * LinkType: LINKTYPE_* of the interface the frame was captured on
+ * Data: the captured bytes, valid until the next packet is read
+ * Length: the length of the frame on the wire, larger than the data if truncated by the snapshot length
+ */
+type Packet struct {
+	Timestamp time.Time
+	LinkType  uint16
+	Data      []byte
+	Length    int
+}
+
+// pcapng interface description
+type captureInterface struct {
+	linkType   uint16
+	snapLength uint32
+	resolution uint64 // timestamp units per second
+	offset     int64  // seconds added to the timestamps
+}
+
+/* Reader reads the packets of a pcap or pcapng capture
+ * the format and the byte order are detected from the file header
+ */
+type Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	ng    bool
+	// pcap
+	linkType uint16
+	nano     bool
+	// pcapng, the interfaces of the current section
+	interfaces []captureInterface
+
+	buffer []byte
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r)}
+	magic, err := reader.r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errinfo.ErrInvalidCapture, err)
+	}
+	if binary.LittleEndian.Uint32(magic) == BLOCK_SECTION_HEADER {
+		reader.ng = true
+		// the section header block is read as the first block
+		return reader, nil
+	}
+
+	header, err := reader.read(PCAP_HEADER_LENGTH)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header) {
+		case MAGIC_MICROSECONDS:
+			reader.order = order
+		case MAGIC_NANOSECONDS:
+			reader.order, reader.nano = order, true
+		}
+	}
+	if reader.order == nil {
+		return nil, fmt.Errorf("%w: unknown magic number %x", errinfo.ErrInvalidCapture, header[:4])
+	}
+	// the upper bits carry the FCS length
+	reader.linkType = uint16(reader.order.Uint32(header[20:]))
+	return reader, nil
+}
+
+/* Next returns the next packet, io.EOF at the end of the capture,
+ * an errinfo.ErrInvalidCapture wrapping io.ErrUnexpectedEOF if the capture ends in the middle of the packet
+ */
+func (r *Reader) Next() (*Packet, error) {
+	if r.ng {
+		return r.nextBlock()
+	}
+	header, err := r.read(PCAP_RECORD_HEADER_LENGTH)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	seconds, fraction := r.order.Uint32(header), r.order.Uint32(header[4:])
+	captured, length := r.order.Uint32(header[8:]), r.order.Uint32(header[12:])
+	if captured > MAX_PACKET_LENGTH {
+		return nil, fmt.Errorf("%w: record of %v bytes", errinfo.ErrInvalidCapture, captured)
+	}
+	data, err := r.read(int(captured))
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	if !r.nano {
+		fraction *= 1000
+	}
+	return &Packet{
+		Timestamp: time.Unix(int64(seconds), int64(fraction)),
+		LinkType:  r.linkType,
+		Data:      data,
+		Length:    int(length),
+	}, nil
+}
+
+// nextBlock reads the pcapng blocks until a packet block
+func (r *Reader) nextBlock() (*Packet, error) {
+	for {
+		head, err := r.r.Peek(8)
+		if err == io.EOF && len(head) == 0 {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if binary.LittleEndian.Uint32(head) == BLOCK_SECTION_HEADER {
+			// a new section may change the byte order, given by the magic following the length
+			magic, err := r.r.Peek(12)
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			switch {
+			case binary.LittleEndian.Uint32(magic[8:]) == BYTE_ORDER_MAGIC:
+				r.order = binary.LittleEndian
+			case binary.BigEndian.Uint32(magic[8:]) == BYTE_ORDER_MAGIC:
+				r.order = binary.BigEndian
+			default:
+				return nil, fmt.Errorf("%w: unknown byte order magic %x", errinfo.ErrInvalidCapture, magic[8:])
+			}
+			r.interfaces = r.interfaces[:0]
+		}
+		if r.order == nil {
+			return nil, fmt.Errorf("%w: no section header", errinfo.ErrInvalidCapture)
+		}
+
+		blockType, length := r.order.Uint32(head), r.order.Uint32(head[4:])
+		if length < BLOCK_HEADER_LENGTH || length%4 != 0 || length > MAX_BLOCK_LENGTH {
+			return nil, fmt.Errorf("%w: block %x of %v bytes", errinfo.ErrInvalidCapture, blockType, length)
+		}
+		block, err := r.read(int(length))
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		body := block[8 : length-4]
+
+		switch blockType {
+		case BLOCK_INTERFACE_DESCRIPTION:
+			if err := r.addInterface(body); err != nil {
+				return nil, err
+			}
+		case BLOCK_ENHANCED_PACKET:
+			return r.enhancedPacket(body)
+		case BLOCK_SIMPLE_PACKET:
+			return r.simplePacket(body)
+		default:
+			// statistics, name resolution and custom blocks
+		}
+	}
+}
+
+func (r *Reader) addInterface(body []byte) error {
+	if len(body) < 8 {
+		return fmt.Errorf("%w: interface description of %v bytes", errinfo.ErrInvalidCapture, len(body))
+	}
+	i := captureInterface{
+		linkType:   r.order.Uint16(body),
+		snapLength: r.order.Uint32(body[4:]),
+		resolution: 1000000,
+	}
+	options := body[8:]
+	for len(options) >= 4 {
+		code, length := r.order.Uint16(options), int(r.order.Uint16(options[2:]))
+		if code == OPTION_END_OF_OPT || 4+length > len(options) {
+			break
+		}
+		value := options[4 : 4+length]
+		switch {
+		case code == OPTION_IF_TSRESOL && length == 1:
+			// negative power of 10, or of 2 if the most significant bit is set
+			exponent := uint64(value[0] & 0x7F)
+			if value[0]&0x80 != 0 {
+				i.resolution = 1 << min(exponent, 63)
+			} else {
+				i.resolution = 1
+				for ; exponent > 0 && i.resolution <= 1e18; exponent-- {
+					i.resolution *= 10
+				}
+			}
+		case code == OPTION_IF_TSOFFSET && length == 8:
+			i.offset = int64(r.order.Uint64(value))
+		}
+		// the values are padded to 32 bits
+		options = options[min(4+(length+3)&^3, len(options)):]
+	}
+	r.interfaces = append(r.interfaces, i)
+	return nil
+}
+
+func (r *Reader) enhancedPacket(body []byte) (*Packet, error) {
+	if len(body) < 20 {
+		return nil, fmt.Errorf("%w: enhanced packet of %v bytes", errinfo.ErrInvalidCapture, len(body))
+	}
+	id := r.order.Uint32(body)
+	if int(id) >= len(r.interfaces) {
+		return nil, fmt.Errorf("%w: unknown interface %v", errinfo.ErrInvalidCapture, id)
+	}
+	i := r.interfaces[id]
+	units := uint64(r.order.Uint32(body[4:]))<<32 | uint64(r.order.Uint32(body[8:]))
+	captured, length := r.order.Uint32(body[12:]), r.order.Uint32(body[16:])
+	if int(captured) > len(body)-20 {
+		return nil, fmt.Errorf("%w: enhanced packet of %v bytes in %v bytes", errinfo.ErrInvalidCapture, captured, len(body))
+	}
+
+	// the fraction of a second in nanoseconds, without overflowing for fine resolutions
+	hi, lo := bits.Mul64(units%i.resolution, uint64(time.Second))
+	nanoseconds, _ := bits.Div64(hi, lo, i.resolution)
+	return &Packet{
+		Timestamp: time.Unix(int64(units/i.resolution)+i.offset, int64(nanoseconds)),
+		LinkType:  i.linkType,
+		Data:      body[20 : 20+captured],
+		Length:    int(length),
+	}, nil
+}
+
+func (r *Reader) simplePacket(body []byte) (*Packet, error) {
+	if len(body) < 4 || len(r.interfaces) == 0 {
+		return nil, fmt.Errorf("%w: simple packet without interface", errinfo.ErrInvalidCapture)
+	}
+	i := r.interfaces[0]
+	length := r.order.Uint32(body)
+	captured := min(length, uint32(len(body)-4))
+	if i.snapLength > 0 {
+		captured = min(captured, i.snapLength)
+	}
+	return &Packet{
+		LinkType: i.linkType,
+		Data:     body[4 : 4+captured],
+		Length:   int(length),
+	}, nil
+}
+
+// read returns the next n bytes in the reused buffer
+func (r *Reader) read(n int) ([]byte, error) {
+	if cap(r.buffer) < n {
+		r.buffer = make([]byte, n)
+	}
+	data := r.buffer[:n]
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// unexpected reports the capture ending in the middle of a record or block
+func unexpected(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: truncated: %w", errinfo.ErrInvalidCapture, io.ErrUnexpectedEOF)
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package rtp
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+)
+
+const (
+	VERSION                 = 2
+	HEADER_LENGTH           = 12 // fixed header of RFC 3550 5.1
+	EXTENSION_HEADER_LENGTH = 4  // profile and length of RFC 3550 5.3.1
+
+	PAYLOAD_TYPE_MP2T = 33 // RFC 3551
+)
+
+/* Header is the header of a rtp packet, RFC 3550 5.1
+ * Extension: the profile defined header extension, nil if not present
+ * Padding: padding bytes removed from the end of the payload
+ */
+type Header struct {
+	Marker           bool
+	PayloadType      uint8
+	Sequence         uint16
+	Timestamp        uint32
+	SSRC             uint32
+	CSRC             []uint32
+	ExtensionProfile uint16
+	Extension        []byte
+	Padding          int
+}
+
+/* Parse the rtp packet
+ * return the header and the payload without the header, extension and padding
+ * return errinfo.ErrInvalidRtp if the data is not a rtp packet
+ */
+func Parse(data []byte) (*Header, []byte, error) {
+	if len(data) < HEADER_LENGTH {
+		return nil, nil, fmt.Errorf("%w: %v bytes", errinfo.ErrInvalidRtp, len(data))
+	}
+	if version := data[0] >> 6; version != VERSION {
+		return nil, nil, fmt.Errorf("%w: version %v", errinfo.ErrInvalidRtp, version)
+	}
+	h := &Header{
+		Marker:      data[1]&0x80 != 0,
+		PayloadType: data[1] & 0x7F,
+		Sequence:    binary.BigEndian.Uint16(data[2:]),
+		Timestamp:   binary.BigEndian.Uint32(data[4:]),
+		SSRC:        binary.BigEndian.Uint32(data[8:]),
+	}
+	end := len(data)
+	if data[0]&0x20 != 0 {
+		// the last byte counts the padding including itself
+		h.Padding = int(data[end-1])
+		if h.Padding == 0 || HEADER_LENGTH+h.Padding > end {
+			return nil, nil, fmt.Errorf("%w: padding of %v bytes in %v bytes", errinfo.ErrInvalidRtp, h.Padding, len(data))
+		}
+		end -= h.Padding
+	}
+
+	start := HEADER_LENGTH
+	csrcCount := int(data[0] & 0x0F)
+	if start+4*csrcCount > end {
+		return nil, nil, fmt.Errorf("%w: %v csrc in %v bytes", errinfo.ErrInvalidRtp, csrcCount, len(data))
+	}
+	if csrcCount > 0 {
+		h.CSRC = make([]uint32, csrcCount)
+		for i := range h.CSRC {
+			h.CSRC[i] = binary.BigEndian.Uint32(data[start+4*i:])
+		}
+		start += 4 * csrcCount
+	}
+
+	if data[0]&0x10 != 0 {
+		if start+EXTENSION_HEADER_LENGTH > end {
+			return nil, nil, fmt.Errorf("%w: extension in %v bytes", errinfo.ErrInvalidRtp, len(data))
+		}
+		h.ExtensionProfile = binary.BigEndian.Uint16(data[start:])
+		length := 4 * int(binary.BigEndian.Uint16(data[start+2:]))
+		start += EXTENSION_HEADER_LENGTH
+		if start+length > end {
+			return nil, nil, fmt.Errorf("%w: extension of %v bytes in %v bytes", errinfo.ErrInvalidRtp, length, len(data))
+		}
+		h.Extension = data[start : start+length]
+		start += length
+	}
+	return h, data[start:end], nil
+}
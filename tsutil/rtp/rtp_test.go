@@ -0,0 +1,76 @@
+package rtp_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+	"github.com/potterxu/tsanalyzer/tsutil/rtp"
+)
+
+func TestParse(t *testing.T) {
+	payload := bytes.Repeat([]byte{0x47, 0x1f, 0xff, 0x10}, 47)
+	// version 2, padding, extension, 1 csrc, marker, mp2t
+	data := []byte{0xb1, 0x80 | rtp.PAYLOAD_TYPE_MP2T, 0x12, 0x34, 0x00, 0x01, 0x00, 0x02, 0xaa, 0xbb, 0xcc, 0xdd,
+		0x00, 0x00, 0x00, 0x07,
+		0xbe, 0xde, 0x00, 0x01, 0x10, 0x20, 0x30, 0x40}
+	data = append(data, payload...)
+	data = append(data, 0x00, 0x00, 0x03)
+
+	h, got, err := rtp.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Marker || h.PayloadType != rtp.PAYLOAD_TYPE_MP2T || h.Sequence != 0x1234 || h.Timestamp != 0x10002 || h.SSRC != 0xaabbccdd {
+		t.Errorf("header not match: %+v", *h)
+	}
+	if len(h.CSRC) != 1 || h.CSRC[0] != 7 || h.ExtensionProfile != 0xbede || len(h.Extension) != 4 || h.Padding != 3 {
+		t.Errorf("header not match: %+v", *h)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("payload not match: %x", got)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := [][]byte{
+		{0x80, 0x21, 0x00},
+		// raw ts
+		append([]byte{0x47, 0x1f, 0xff, 0x10}, make([]byte, 184)...),
+		// extension longer than the packet
+		{0x90, 0x21, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0xbe, 0xde, 0x00, 0x10},
+		// padding longer than the packet
+		{0xa0, 0x21, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0xff},
+	}
+	for _, data := range cases {
+		if _, _, err := rtp.Parse(data); !errors.Is(err, errinfo.ErrInvalidRtp) {
+			t.Errorf("%x: expected %v, but get %v", data, errinfo.ErrInvalidRtp, err)
+		}
+	}
+}
+
+func TestSequenceTracker(t *testing.T) {
+	tracker := rtp.NewSequenceTracker()
+	sequences := []uint16{65533, 65534, 0, 65535, 3, 3, 1, 2, 4, 20000}
+	expected := []rtp.SequenceStatus{
+		rtp.SEQUENCE_IN_ORDER, rtp.SEQUENCE_IN_ORDER,
+		rtp.SEQUENCE_LOSS, rtp.SEQUENCE_REORDERED, // 65535 arrives after 0
+		rtp.SEQUENCE_LOSS, rtp.SEQUENCE_DUPLICATE,
+		rtp.SEQUENCE_REORDERED, rtp.SEQUENCE_REORDERED,
+		rtp.SEQUENCE_IN_ORDER, rtp.SEQUENCE_RESTART,
+	}
+	for i, sequence := range sequences {
+		if status, _ := tracker.Update(sequence); status != expected[i] {
+			t.Errorf("sequence %v: expected status %v, but get %v", sequence, expected[i], status)
+		}
+	}
+	stats := tracker.Stats()
+	if stats.Received != 10 || stats.Lost != 0 || stats.Duplicated != 1 || stats.Reordered != 3 || stats.Restarts != 1 {
+		t.Errorf("stats not match: %+v", stats)
+	}
+
+	if status, lost := tracker.Update(20003); status != rtp.SEQUENCE_LOSS || lost != 2 {
+		t.Errorf("expected 2 lost, but get %v %v", status, lost)
+	}
+}
@@ -0,0 +1,117 @@
+package rtp
+
+const (
+	// limits of RFC 3550 A.1, a larger jump restarts the sequence
+	MAX_DROPOUT  = 3000
+	MAX_MISORDER = 100
+
+	SEQUENCE_MODULO = 1 << 16
+)
+
+type SequenceStatus int
+
+const (
+	SEQUENCE_IN_ORDER  SequenceStatus = iota
+	SEQUENCE_LOSS                     // datagrams are missing before this one
+	SEQUENCE_DUPLICATE                // already received
+	SEQUENCE_REORDERED                // a datagram counted as lost arrived late
+	SEQUENCE_RESTART                  // the sequence jumped, e.g. the sender restarted
+)
+
+/* SequenceStats counts the datagrams of a rtp stream
+ * Received: datagrams received, including duplicated ones
+ * Lost: missing datagrams, the reordered ones are not counted
+ * Duplicated, Reordered: datagrams received twice or after a later one
+ * Restarts: jumps larger than MAX_DROPOUT or MAX_MISORDER
+ */
+type SequenceStats struct {
+	Received   uint64
+	Lost       uint64
+	Duplicated uint64
+	Reordered  uint64
+	Restarts   uint64
+}
+
+// SequenceTracker follows the sequence numbers of a rtp stream
+type SequenceTracker struct {
+	started bool
+	highest int64                   // extended highest sequence number
+	seen    [MAX_MISORDER + 1]int64 // extended sequence numbers received recently, by sequence modulo the size
+	stats   SequenceStats
+}
+
+func NewSequenceTracker() *SequenceTracker {
+	t := &SequenceTracker{}
+	t.Reset()
+	return t
+}
+
+// Reset forgets the sequence, e.g. when the SSRC changes
+func (t *SequenceTracker) Reset() {
+	t.started = false
+	for i := range t.seen {
+		t.seen[i] = -1
+	}
+}
+
+func (t *SequenceTracker) Stats() SequenceStats {
+	return t.stats
+}
+
+/* Update the tracker with the sequence number of a datagram
+ * return the status of the datagram
+ * return the number of datagrams lost before it if SEQUENCE_LOSS
+ */
+func (t *SequenceTracker) Update(sequence uint16) (SequenceStatus, int) {
+	t.stats.Received++
+	if !t.started {
+		t.restart(sequence)
+		return SEQUENCE_IN_ORDER, 0
+	}
+
+	// the extended sequence number closest to the highest one
+	extended := t.highest&^(SEQUENCE_MODULO-1) | int64(sequence)
+	if extended-t.highest > SEQUENCE_MODULO/2 {
+		extended -= SEQUENCE_MODULO
+	} else if t.highest-extended > SEQUENCE_MODULO/2 {
+		extended += SEQUENCE_MODULO
+	}
+
+	delta := extended - t.highest
+	switch {
+	case delta > MAX_DROPOUT || delta < -MAX_MISORDER:
+		t.stats.Restarts++
+		t.restart(sequence)
+		return SEQUENCE_RESTART, 0
+	case t.seen[t.slot(extended)] == extended:
+		t.stats.Duplicated++
+		return SEQUENCE_DUPLICATE, 0
+	case delta < 0:
+		t.stats.Reordered++
+		if t.stats.Lost > 0 {
+			t.stats.Lost--
+		}
+		t.seen[t.slot(extended)] = extended
+		return SEQUENCE_REORDERED, 0
+	}
+
+	t.highest = extended
+	t.seen[t.slot(extended)] = extended
+	if delta > 1 {
+		t.stats.Lost += uint64(delta - 1)
+		return SEQUENCE_LOSS, int(delta - 1)
+	}
+	return SEQUENCE_IN_ORDER, 0
+}
+
+func (t *SequenceTracker) restart(sequence uint16) {
+	t.Reset()
+	t.started = true
+	// a cycle ahead, the datagrams reordered before the first one stay positive
+	t.highest = SEQUENCE_MODULO + int64(sequence)
+	t.seen[t.slot(t.highest)] = t.highest
+}
+
+func (t *SequenceTracker) slot(extended int64) int {
+	return int(extended % int64(len(t.seen)))
+}
@@ -31,6 +31,9 @@ type Accumulator interface {
 
 	// force reset the state of the accumulator
 	Reset()
+
+	// return the data accumulated so far for every pid and reset, e.g. at the end of the stream
+	Flush() []*AccumulatorResult
 }
 
 type accumulator struct {
@@ -74,6 +77,16 @@ func (a *accumulator) Reset() {
 	}
 }
 
+func (a *accumulator) Flush() []*AccumulatorResult {
+	results := make([]*AccumulatorResult, 0)
+	for pid := 0; pid <= MAX_PID; pid++ {
+		if a.payloads[pid].Len() > 0 {
+			results = append(results, a.get(pid))
+		}
+	}
+	return results
+}
+
 func (a *accumulator) reset(pid int) {
 	a.payloads[pid].Reset()
 }
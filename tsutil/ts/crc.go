@@ -0,0 +1,31 @@
+package ts
+
+// CRC32 of ISO/IEC 13818-1 Annex A: polynomial 0x04C11DB7, initial 0xFFFFFFFF, not reflected
+const CRC32_POLY uint32 = 0x04C11DB7
+
+var crc32Table = makeCrc32Table()
+
+func makeCrc32Table() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ CRC32_POLY
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
+
+// Crc32 returns the CRC32 of the data, 0 for a section including its CRC_32 field if valid
+func Crc32(data []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, b := range data {
+		crc = crc<<8 ^ crc32Table[byte(crc>>24)^b]
+	}
+	return crc
+}
@@ -0,0 +1,76 @@
+package ts
+
+import (
+	"fmt"
+
+	"github.com/Comcast/gots/v2/pes"
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+)
+
+const (
+	PES_START_CODE    = 0x000001
+	PES_HEADER_LENGTH = 6 // start code, stream id and PES_packet_length
+
+	NO_TIMESTAMP int64 = -1
+)
+
+/* PesPacket is a pes packet accumulated from ts packets
+ * Pid: the pid of the ts packets
+ * StreamId: stream_id of the pes header
+ * Length: PES_packet_length, 0 if unbounded
+ * DataAligned: data_alignment_indicator
+ * Pts, Dts: in 90kHz, NO_TIMESTAMP if not present
+ * Payload: the data following the pes header
+ * FirstIndex, LastIndex: index of the first and last ts packets carrying the pes packet, -1 if unknown
+ */
+type PesPacket struct {
+	Pid         int
+	StreamId    uint8
+	Length      int
+	DataAligned bool
+	Pts         int64
+	Dts         int64
+	Payload     []byte
+	FirstIndex  int64
+	LastIndex   int64
+}
+
+// ParsePes parses the data accumulated for the pid into a pes packet,
+// the data must start with the pes start code and hold PES_packet_length bytes if bounded
+func ParsePes(pid int, data []byte) (*PesPacket, error) {
+	if len(data) < PES_HEADER_LENGTH+1 {
+		return nil, fmt.Errorf("%w: %v bytes", errinfo.ErrInvalidPes, len(data))
+	}
+	if prefix := int(data[0])<<16 | int(data[1])<<8 | int(data[2]); prefix != PES_START_CODE {
+		return nil, fmt.Errorf("%w: start code %06x", errinfo.ErrInvalidPes, prefix)
+	}
+	length := int(data[4])<<8 | int(data[5])
+	if length > 0 {
+		if len(data) < PES_HEADER_LENGTH+length {
+			return nil, fmt.Errorf("%w: %v bytes of PES_packet_length %v", errinfo.ErrInvalidPes, len(data)-PES_HEADER_LENGTH, length)
+		}
+		data = data[:PES_HEADER_LENGTH+length]
+	}
+	header, err := pes.NewPESHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errinfo.ErrInvalidPes, err)
+	}
+	p := &PesPacket{
+		Pid:         pid,
+		StreamId:    header.StreamId(),
+		Length:      length,
+		DataAligned: header.DataAligned(),
+		Pts:         NO_TIMESTAMP,
+		Dts:         NO_TIMESTAMP,
+		Payload:     header.Data(),
+		FirstIndex:  -1,
+		LastIndex:   -1,
+	}
+	if header.HasPTS() {
+		p.Pts = int64(header.PTS())
+	}
+	if header.HasDTS() {
+		p.Dts = int64(header.DTS())
+	}
+	return p, nil
+}
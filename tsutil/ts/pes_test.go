@@ -0,0 +1,42 @@
+package ts_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
+)
+
+func TestParsePes(t *testing.T) {
+	// video pes with pts 0x12345 and dts 0x12344, 2 bytes of payload
+	data := []byte{0x00, 0x00, 0x01, 0xe0, 0x00, 0x0f, 0x84, 0xc0, 0x0a,
+		0x31, 0x00, 0x05, 0x46, 0x8b, 0x11, 0x00, 0x05, 0x46, 0x89, 0xaa, 0xbb}
+	pes, err := ts.ParsePes(48, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pes.Pid != 48 || pes.StreamId != 0xe0 || pes.Length != 15 || !pes.DataAligned {
+		t.Errorf("header not match: %+v", *pes)
+	}
+	if pes.Pts != 0x12345 || pes.Dts != 0x12344 {
+		t.Errorf("pts %x dts %x", pes.Pts, pes.Dts)
+	}
+	if len(pes.Payload) != 2 || pes.Payload[0] != 0xaa {
+		t.Errorf("payload %x", pes.Payload)
+	}
+}
+
+func TestParsePesInvalid(t *testing.T) {
+	cases := [][]byte{
+		{0x00, 0x00, 0x01, 0xe0},
+		{0x00, 0x00, 0x02, 0xe0, 0x00, 0x00, 0x80, 0x00, 0x00},
+		// truncated by the loss of a ts packet
+		{0x00, 0x00, 0x01, 0xe0, 0x00, 0x20, 0x80, 0x00, 0x00},
+	}
+	for _, data := range cases {
+		if _, err := ts.ParsePes(48, data); !errors.Is(err, errinfo.ErrInvalidPes) {
+			t.Errorf("%x: expected %v, but get %v", data, errinfo.ErrInvalidPes, err)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package ts
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/Comcast/gots/v2"
+	"github.com/Comcast/gots/v2/packet"
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+)
+
+const (
+	SECTION_HEADER_LENGTH      = 3    // table_id and section_length
+	SECTION_LONG_HEADER_LENGTH = 8    // the header of sections with section_syntax_indicator
+	MAX_SECTION_LENGTH         = 4096 // including the header, private sections
+	CRC_LENGTH                 = 4
+
+	STUFFING_BYTE = 0xFF
+
+	TABLE_ID_PAT = 0x00
+	TABLE_ID_TOT = 0x73 // carries a CRC_32 without section_syntax_indicator
+)
+
+/* Section is a PSI/SI section assembled from ts packets
+ * Pid: the pid of the ts packets
+ * TableId: table_id
+ * SyntaxIndicator: section_syntax_indicator, the following fields up to LastNumber are 0 if not set
+ * TableIdExtension: table_id_extension, e.g. transport_stream_id of the PAT or program_number of the PMT
+ * Version, CurrentNext, Number, LastNumber: version_number, current_next_indicator, section_number, last_section_number
+ * Data: the whole section, including the header and the CRC_32
+ * FirstIndex, LastIndex: index of the first and last ts packets carrying the section
+ */
+type Section struct {
+	Pid              int
+	TableId          uint8
+	SyntaxIndicator  bool
+	TableIdExtension uint16
+	Version          uint8
+	CurrentNext      bool
+	Number           uint8
+	LastNumber       uint8
+	Data             []byte
+	FirstIndex       int64
+	LastIndex        int64
+}
+
+// HasCrc returns whether the section ends with a CRC_32
+func (s *Section) HasCrc() bool {
+	return s.SyntaxIndicator || s.TableId == TABLE_ID_TOT
+}
+
+// Payload returns the data following the header, without the CRC_32
+func (s *Section) Payload() []byte {
+	start, end := SECTION_HEADER_LENGTH, len(s.Data)
+	if s.SyntaxIndicator {
+		start = SECTION_LONG_HEADER_LENGTH
+	}
+	if s.HasCrc() {
+		end -= CRC_LENGTH
+	}
+	return s.Data[start:end]
+}
+
+// PatPids returns the pids of the PMTs and of the network listed in a PAT section
+func PatPids(s *Section) []int {
+	if s.TableId != TABLE_ID_PAT {
+		return nil
+	}
+	pids := make([]int, 0)
+	payload := s.Payload()
+	for i := 0; i+4 <= len(payload); i += 4 {
+		pids = append(pids, int(binary.BigEndian.Uint16(payload[i+2:])&MAX_PID))
+	}
+	return pids
+}
+
+/* SectionStats counts the sections of a stream
+ * Sections: valid sections assembled
+ * CrcErrors: TR 101 290 2.2 CRC_error, sections dropped for a CRC_32 mismatch
+ * Invalid: sections dropped for an invalid header or pointer_field, or not completed
+ * Discontinuities: continuity_counter errors, the partial section is dropped
+ */
+type SectionStats struct {
+	Sections        uint64
+	CrcErrors       uint64
+	Invalid         uint64
+	Discontinuities uint64
+}
+
+/* SectionAssembler assembles the sections of ts packets,
+ * a payload unit start packet may end a section before its pointer_field
+ * and carry several sections after it
+ */
+type SectionAssembler struct {
+	pids  [MAX_PID + 1]*sectionState
+	stats SectionStats
+}
+
+// sectionState is the section being assembled for a pid
+type sectionState struct {
+	buffer  []byte
+	started bool // a payload unit start was found since the last error
+	first   int64
+	cc      int
+	ccValid bool
+}
+
+func NewSectionAssembler() *SectionAssembler {
+	return &SectionAssembler{}
+}
+
+func (a *SectionAssembler) Stats() SectionStats {
+	return a.stats
+}
+
+/* Add the packet at the index to the assembler
+ * return the valid sections completed by the packet
+ * return the problems found, wrapping errinfo.ErrCrc or errinfo.ErrInvalidSection
+ */
+func (a *SectionAssembler) Add(pkt *packet.Packet, index int64) ([]*Section, error) {
+	if err := pkt.CheckErrors(); err != nil {
+		return nil, err
+	}
+	pid := packet.Pid(pkt)
+	payload, err := packet.Payload(pkt)
+	if err == gots.ErrNoPayload {
+		return nil, nil
+	}
+	s := a.pids[pid]
+	if s == nil {
+		s = &sectionState{}
+		a.pids[pid] = s
+	}
+	if err != nil {
+		a.drop(s)
+		return nil, err
+	}
+
+	cc := pkt.ContinuityCounter()
+	if s.ccValid && cc == s.cc {
+		// duplicate packet
+		return nil, nil
+	}
+	if s.ccValid && cc != (s.cc+1)&0x0F && s.started {
+		a.stats.Discontinuities++
+		a.drop(s)
+	}
+	s.cc, s.ccValid = cc, true
+
+	sections := make([]*Section, 0)
+	errs := make([]error, 0)
+	if !pkt.PayloadUnitStartIndicator() {
+		if s.started {
+			sections, errs = a.consume(s, pid, payload, index, sections, errs)
+		}
+		return sections, errors.Join(errs...)
+	}
+
+	pointer := int(payload[0])
+	if 1+pointer > len(payload) {
+		a.stats.Invalid++
+		a.drop(s)
+		return nil, fmt.Errorf("%w: pid %v pointer_field %v", errinfo.ErrInvalidSection, pid, pointer)
+	}
+	if s.started {
+		// the bytes before the pointer_field end the previous section
+		sections, errs = a.consume(s, pid, payload[1:1+pointer], index, sections, errs)
+		if s.started && len(s.buffer) > 0 {
+			a.stats.Invalid++
+			errs = append(errs, fmt.Errorf("%w: pid %v section not completed", errinfo.ErrInvalidSection, pid))
+		}
+	}
+	a.drop(s)
+	s.started = true
+	s.first = index
+	sections, errs = a.consume(s, pid, payload[1+pointer:], index, sections, errs)
+	return sections, errors.Join(errs...)
+}
+
+// drop the partial section, wait for the next payload unit start
+func (a *SectionAssembler) drop(s *sectionState) {
+	s.buffer = s.buffer[:0]
+	s.started = false
+}
+
+// consume appends the data to the section being assembled and parses the completed sections
+func (a *SectionAssembler) consume(s *sectionState, pid int, data []byte, index int64, sections []*Section, errs []error) ([]*Section, []error) {
+	s.buffer = append(s.buffer, data...)
+	for len(s.buffer) > 0 {
+		if s.buffer[0] == STUFFING_BYTE {
+			// the rest of the packet is stuffing
+			a.drop(s)
+			break
+		}
+		if len(s.buffer) < SECTION_HEADER_LENGTH {
+			break
+		}
+		length := SECTION_HEADER_LENGTH + int(binary.BigEndian.Uint16(s.buffer[1:])&0x0FFF)
+		if length > MAX_SECTION_LENGTH {
+			a.stats.Invalid++
+			errs = append(errs, fmt.Errorf("%w: pid %v section_length %v", errinfo.ErrInvalidSection, pid, length-SECTION_HEADER_LENGTH))
+			a.drop(s)
+			break
+		}
+		if len(s.buffer) < length {
+			break
+		}
+		section, err := parseSection(pid, s.buffer[:length])
+		if err != nil {
+			if errors.Is(err, errinfo.ErrCrc) {
+				a.stats.CrcErrors++
+			} else {
+				a.stats.Invalid++
+			}
+			errs = append(errs, err)
+		} else {
+			section.FirstIndex, section.LastIndex = s.first, index
+			sections = append(sections, section)
+			a.stats.Sections++
+		}
+		// the next section starts in this packet
+		s.buffer = s.buffer[:copy(s.buffer, s.buffer[length:])]
+		s.first = index
+	}
+	return sections, errs
+}
+
+// parseSection returns a copy of the data as section if valid
+func parseSection(pid int, data []byte) (*Section, error) {
+	s := &Section{
+		Pid:             pid,
+		TableId:         data[0],
+		SyntaxIndicator: data[1]&0x80 != 0,
+	}
+	if s.SyntaxIndicator {
+		if len(data) < SECTION_LONG_HEADER_LENGTH+CRC_LENGTH {
+			return nil, fmt.Errorf("%w: pid %v table_id %v section_length %v", errinfo.ErrInvalidSection, pid, s.TableId, len(data)-SECTION_HEADER_LENGTH)
+		}
+		s.TableIdExtension = binary.BigEndian.Uint16(data[3:])
+		s.Version = data[5] >> 1 & 0x1F
+		s.CurrentNext = data[5]&0x01 != 0
+		s.Number = data[6]
+		s.LastNumber = data[7]
+	}
+	if s.HasCrc() {
+		if len(data) < SECTION_HEADER_LENGTH+CRC_LENGTH {
+			return nil, fmt.Errorf("%w: pid %v table_id %v section_length %v", errinfo.ErrInvalidSection, pid, s.TableId, len(data)-SECTION_HEADER_LENGTH)
+		}
+		if Crc32(data) != 0 {
+			return nil, fmt.Errorf("%w: pid %v table_id %v section_number %v", errinfo.ErrCrc, pid, s.TableId, s.Number)
+		}
+	}
+	s.Data = make([]byte, len(data))
+	copy(s.Data, data)
+	return s, nil
+}
@@ -0,0 +1,157 @@
+package ts_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/Comcast/gots/v2/packet"
+	"github.com/potterxu/tsanalyzer/tsutil/errinfo"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
+)
+
+// makeSection returns a long section of the size with a valid CRC_32
+func makeSection(tableId byte, number byte, size int) []byte {
+	section := make([]byte, size)
+	section[0] = tableId
+	binary.BigEndian.PutUint16(section[1:], 0xB000|uint16(size-3))
+	binary.BigEndian.PutUint16(section[3:], 1)
+	section[5] = 0xC1 | 3<<1 // version 3, current
+	section[6] = number
+	section[7] = number
+	for i := 8; i < size-4; i++ {
+		section[i] = byte(i)
+	}
+	binary.BigEndian.PutUint32(section[size-4:], ts.Crc32(section[:size-4]))
+	return section
+}
+
+// makeSectionPacket returns a packet of the pid carrying the payload, padded with stuffing bytes
+func makeSectionPacket(pid int, start bool, cc int, payload []byte) *packet.Packet {
+	var pkt packet.Packet
+	pkt[0] = packet.SyncByte
+	pkt[1] = byte(pid >> 8 & 0x1F)
+	if start {
+		pkt[1] |= 0x40
+	}
+	pkt[2] = byte(pid)
+	pkt[3] = 0x10 | byte(cc&0x0F)
+	n := copy(pkt[4:], payload)
+	for i := 4 + n; i < packet.PacketSize; i++ {
+		pkt[i] = ts.STUFFING_BYTE
+	}
+	return &pkt
+}
+
+// sectionPackets returns 2 packets carrying a section of 200 bytes, then 2 sections of 20 bytes
+func sectionPackets(cc1 int, corrupt bool) []*packet.Packet {
+	a, b, c := makeSection(0x42, 0, 200), makeSection(0x42, 1, 20), makeSection(0x46, 0, 20)
+	if corrupt {
+		b[10] ^= 0xFF
+	}
+	first := append([]byte{0}, a[:183]...)
+	second := append([]byte{byte(len(a) - 183)}, a[183:]...)
+	second = append(append(second, b...), c...)
+	return []*packet.Packet{makeSectionPacket(17, true, 0, first), makeSectionPacket(17, true, cc1, second)}
+}
+
+func assemble(a *ts.SectionAssembler, pkts []*packet.Packet) ([]*ts.Section, error) {
+	sections := make([]*ts.Section, 0)
+	errs := make([]error, 0)
+	for i, pkt := range pkts {
+		s, err := a.Add(pkt, int64(i))
+		sections = append(sections, s...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return sections, errors.Join(errs...)
+}
+
+func TestCrc32(t *testing.T) {
+	// CRC-32/MPEG-2 check value
+	if crc := ts.Crc32([]byte("123456789")); crc != 0x0376E6E7 {
+		t.Errorf("crc not match, expected 0376e6e7, but get %08x", crc)
+	}
+}
+
+func TestSectionAssembler(t *testing.T) {
+	a := ts.NewSectionAssembler()
+	sections, err := assemble(a, sectionPackets(1, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 3 {
+		t.Fatalf("section cnt not match, expected 3, but get %v", len(sections))
+	}
+	s := sections[0]
+	if s.Pid != 17 || s.TableId != 0x42 || !s.SyntaxIndicator || s.TableIdExtension != 1 || s.Version != 3 || !s.CurrentNext ||
+		len(s.Data) != 200 || len(s.Payload()) != 188 || s.FirstIndex != 0 || s.LastIndex != 1 {
+		t.Errorf("section not match: %+v", *s)
+	}
+	if s := sections[1]; s.Number != 1 || s.FirstIndex != 1 || s.LastIndex != 1 {
+		t.Errorf("section not match: %+v", *s)
+	}
+	if s := sections[2]; s.TableId != 0x46 || len(s.Data) != 20 {
+		t.Errorf("section not match: %+v", *s)
+	}
+	if stats := a.Stats(); stats.Sections != 3 || stats.CrcErrors != 0 {
+		t.Errorf("stats not match: %+v", stats)
+	}
+}
+
+func TestSectionAssemblerCrcError(t *testing.T) {
+	a := ts.NewSectionAssembler()
+	sections, err := assemble(a, sectionPackets(1, true))
+	if !errors.Is(err, errinfo.ErrCrc) {
+		t.Errorf("expected %v, but get %v", errinfo.ErrCrc, err)
+	}
+	if len(sections) != 2 || sections[1].TableId != 0x46 {
+		t.Errorf("sections not match: %v", sections)
+	}
+	if stats := a.Stats(); stats.Sections != 2 || stats.CrcErrors != 1 {
+		t.Errorf("stats not match: %+v", stats)
+	}
+}
+
+func TestSectionAssemblerDiscontinuity(t *testing.T) {
+	a := ts.NewSectionAssembler()
+	// a packet is lost, the first section is dropped
+	sections, err := assemble(a, sectionPackets(2, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 || sections[0].Number != 1 {
+		t.Errorf("sections not match: %v", sections)
+	}
+	if stats := a.Stats(); stats.Sections != 2 || stats.Discontinuities != 1 {
+		t.Errorf("stats not match: %+v", stats)
+	}
+}
+
+func TestSectionAssemblerPat(t *testing.T) {
+	data, err := os.ReadFile("../data/data.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := ts.NewSectionAssembler()
+	pids := make([]int, 0)
+	for i := 0; i+packet.PacketSize <= len(data); i += packet.PacketSize {
+		pkt := packet.Packet(data[i : i+packet.PacketSize])
+		if packet.Pid(&pkt) != 0 {
+			continue
+		}
+		sections, err := a.Add(&pkt, int64(i/packet.PacketSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range sections {
+			pids = append(pids, ts.PatPids(s)...)
+		}
+	}
+	if !slices.Equal(pids, []int{480}) {
+		t.Errorf("pmt pids not match, expected [480], but get %v", pids)
+	}
+}
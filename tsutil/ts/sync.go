@@ -0,0 +1,193 @@
+package ts
+
+import (
+	"github.com/Comcast/gots/v2/packet"
+)
+
+const (
+	SYNC_BYTE = packet.SyncByte
+
+	TS_PACKET_SIZE   = packet.PacketSize // 188
+	M2TS_PACKET_SIZE = 192               // 4-byte arrival timestamp header followed by a ts packet
+	RS_PACKET_SIZE   = 204               // ts packet followed by 16 bytes of Reed-Solomon parity
+
+	M2TS_TIMESTAMP_MASK = 0x3FFFFFFF // 30-bit arrival time stamp in 27MHz of the M2TS header
+
+	// hysteresis of TR 101 290 1.1 TS_sync_loss
+	SYNC_ACQUIRE_COUNT = 5 // consecutive sync bytes to acquire sync
+	SYNC_LOSS_COUNT    = 2 // consecutive corrupted sync bytes to lose sync
+)
+
+// PACKET_SIZES are detected by a Synchronizer created with size 0
+var PACKET_SIZES = []int{TS_PACKET_SIZE, M2TS_PACKET_SIZE, RS_PACKET_SIZE}
+
+// SyncByteOffset returns the position of the sync byte in a packet of the size
+func SyncByteOffset(size int) int {
+	if size == M2TS_PACKET_SIZE {
+		return M2TS_PACKET_SIZE - TS_PACKET_SIZE
+	}
+	return 0
+}
+
+/* SyncStats counts the synchronization events of a stream
+ * SyncLosses: TR 101 290 1.1 TS_sync_loss, sync lost after SYNC_LOSS_COUNT corrupted sync bytes
+ * SyncByteErrors: TR 101 290 1.2 Sync_byte_error, corrupted sync bytes while in sync
+ * SkippedBytes: bytes discarded while searching for sync or with a corrupted sync byte
+ * Packets: packets returned
+ */
+type SyncStats struct {
+	SyncLosses     uint64
+	SyncByteErrors uint64
+	SkippedBytes   uint64
+	Packets        uint64
+}
+
+/* Synchronizer splits a byte stream into packets of one of PACKET_SIZES,
+ * sync is acquired when SYNC_ACQUIRE_COUNT sync bytes are found at consecutive packet strides,
+ * and searched again after SYNC_LOSS_COUNT consecutive corrupted sync bytes.
+ * Packets with a corrupted sync byte are skipped
+ */
+type Synchronizer struct {
+	size int // 0 until detected
+
+	store  []byte // reused between writes
+	buffer []byte // pending bytes in store
+	offset int64  // stream offset of buffer[0]
+	synced bool
+	errors int  // consecutive corrupted sync bytes
+	eof    bool // no more data, acquire sync with the remaining packets
+
+	stats   SyncStats
+	lostAt  int64 // stream offset of the last sync loss
+	newLoss bool
+}
+
+// NewSynchronizer returns a synchronizer for packets of the size, 0 to detect the size
+func NewSynchronizer(size int) *Synchronizer {
+	return &Synchronizer{
+		size:   size,
+		lostAt: -1,
+	}
+}
+
+// Write appends the next bytes of the stream
+func (s *Synchronizer) Write(data []byte) {
+	n := copy(s.store, s.buffer)
+	s.store = append(s.store[:n], data...)
+	s.buffer = s.store
+}
+
+// Flush tells that the stream ended, so sync can be acquired with less than SYNC_ACQUIRE_COUNT packets
+func (s *Synchronizer) Flush() {
+	s.eof = true
+}
+
+/* Next returns the next packet and its offset in the stream
+ * the packet is only valid until the next call to Write
+ * return false if more data is required
+ */
+func (s *Synchronizer) Next() ([]byte, int64, bool) {
+	for {
+		if !s.synced && !s.acquire() {
+			return nil, 0, false
+		}
+		if len(s.buffer) < s.size {
+			if s.eof {
+				s.skip(len(s.buffer))
+			}
+			return nil, 0, false
+		}
+		if s.buffer[SyncByteOffset(s.size)] == SYNC_BYTE {
+			s.errors = 0
+			pkt, offset := s.buffer[:s.size], s.offset
+			s.buffer = s.buffer[s.size:]
+			s.offset += int64(s.size)
+			s.stats.Packets++
+			return pkt, offset, true
+		}
+
+		s.stats.SyncByteErrors++
+		s.errors++
+		if s.errors >= SYNC_LOSS_COUNT {
+			s.synced = false
+			s.errors = 0
+			s.stats.SyncLosses++
+			s.lostAt = s.offset
+			s.newLoss = true
+			s.skip(1)
+			continue
+		}
+		s.skip(s.size)
+	}
+}
+
+// acquire searches for sync, the bytes before the first packet are skipped
+func (s *Synchronizer) acquire() bool {
+	sizes := PACKET_SIZES
+	if s.size > 0 {
+		sizes = []int{s.size}
+	}
+	for p := 0; p < len(s.buffer); p++ {
+		undecided := false
+		for _, size := range sizes {
+			matched, complete := s.match(p, size)
+			if matched {
+				s.skip(p)
+				s.size = size
+				s.synced = true
+				return true
+			}
+			undecided = undecided || !complete
+		}
+		if undecided {
+			// wait for more data to decide
+			s.skip(p)
+			return false
+		}
+	}
+	s.skip(len(s.buffer))
+	return false
+}
+
+// match checks the sync bytes of the packets of the size starting from p,
+// complete is false if there is not enough data to decide
+func (s *Synchronizer) match(p int, size int) (matched bool, complete bool) {
+	for i := 0; i < SYNC_ACQUIRE_COUNT; i++ {
+		next := p + SyncByteOffset(size) + i*size
+		if next >= len(s.buffer) {
+			// at the end of the stream, accept the remaining full packets
+			return s.eof && i > 0 && len(s.buffer)-p >= size, s.eof
+		}
+		if s.buffer[next] != SYNC_BYTE {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+func (s *Synchronizer) skip(n int) {
+	s.buffer = s.buffer[n:]
+	s.offset += int64(n)
+	s.stats.SkippedBytes += uint64(n)
+}
+
+// PacketSize returns the size of the packets, 0 if not detected yet
+func (s *Synchronizer) PacketSize() int {
+	return s.size
+}
+
+// Synced reports whether the stream is in sync
+func (s *Synchronizer) Synced() bool {
+	return s.synced
+}
+
+func (s *Synchronizer) Stats() SyncStats {
+	return s.stats
+}
+
+// SyncLoss returns the stream offset of a sync loss happened since the last call
+func (s *Synchronizer) SyncLoss() (int64, bool) {
+	lost := s.newLoss
+	s.newLoss = false
+	return s.lostAt, lost
+}
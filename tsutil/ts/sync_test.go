@@ -0,0 +1,121 @@
+package ts_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/Comcast/gots/v2/packet"
+	"github.com/potterxu/tsanalyzer/tsutil/ts"
+)
+
+// syncPackets writes the stream in chunks and returns the offsets of the packets found
+func syncPackets(data []byte, chunk int) ([]int64, ts.SyncStats) {
+	offsets, stats, _ := syncSizedPackets(data, chunk, packet.PacketSize)
+	return offsets, stats
+}
+
+func syncSizedPackets(data []byte, chunk int, size int) ([]int64, ts.SyncStats, int) {
+	s := ts.NewSynchronizer(size)
+	offsets := make([]int64, 0)
+	next := func() {
+		for {
+			pkt, offset, ok := s.Next()
+			if !ok {
+				return
+			}
+			if pkt[ts.SyncByteOffset(s.PacketSize())] != ts.SYNC_BYTE || len(pkt) != s.PacketSize() {
+				panic("invalid packet")
+			}
+			offsets = append(offsets, offset)
+		}
+	}
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		s.Write(data[:n])
+		data = data[n:]
+		next()
+	}
+	s.Flush()
+	next()
+	return offsets, s.Stats(), s.PacketSize()
+}
+
+func readTestData(t *testing.T) []byte {
+	data, err := os.ReadFile("../data/data.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSynchronizerAligned(t *testing.T) {
+	data := readTestData(t)
+	for _, chunk := range []int{1, 100, 188, 1000, len(data)} {
+		offsets, stats := syncPackets(data, chunk)
+		if len(offsets) != 20 || stats.SkippedBytes != 0 || stats.SyncLosses != 0 {
+			t.Errorf("chunk %v: expected 20 packets without error, but get %v packets, stats %+v\n", chunk, len(offsets), stats)
+		}
+	}
+}
+
+func TestSynchronizerGarbage(t *testing.T) {
+	data := readTestData(t)
+	// starts mid-packet, and a garbage prefix containing sync bytes
+	stream := append([]byte{0x47, 0x00, 0x47, 0x11}, data[100:]...)
+	offsets, stats := syncPackets(stream, 1000)
+	if len(offsets) != 19 || offsets[0] != 4+88 {
+		t.Errorf("expected 19 packets from offset 92, but get %v packets from %v\n", len(offsets), offsets)
+	}
+	if stats.SkippedBytes != 92 {
+		t.Errorf("expected 92 skipped bytes, but get %v\n", stats.SkippedBytes)
+	}
+}
+
+func TestSynchronizerSyncByteError(t *testing.T) {
+	data := bytes.Clone(readTestData(t))
+	data[5*packet.PacketSize] = 0
+	offsets, stats := syncPackets(data, 1000)
+	if len(offsets) != 19 || stats.SyncByteErrors != 1 || stats.SyncLosses != 0 || stats.SkippedBytes != packet.PacketSize {
+		t.Errorf("expected 19 packets and 1 sync byte error, but get %v packets, stats %+v\n", len(offsets), stats)
+	}
+}
+
+func TestSynchronizerSyncLoss(t *testing.T) {
+	data := readTestData(t)
+	// a corrupted burst breaking two sync bytes, followed by a shifted stream
+	stream := append(bytes.Clone(data[:5*packet.PacketSize]), make([]byte, 2*packet.PacketSize+7)...)
+	stream = append(stream, data[5*packet.PacketSize:]...)
+	offsets, stats := syncPackets(stream, 1000)
+	if len(offsets) != 20 || stats.SyncByteErrors != 2 || stats.SyncLosses != 1 {
+		t.Errorf("expected 20 packets, 2 sync byte errors and 1 sync loss, but get %v packets, stats %+v\n", len(offsets), stats)
+	}
+	if offsets[5] != int64(7*packet.PacketSize+7) {
+		t.Errorf("expected resync at %v, but get %v\n", 7*packet.PacketSize+7, offsets[5])
+	}
+}
+
+func TestSynchronizerPacketSize(t *testing.T) {
+	data := readTestData(t)
+	for _, size := range ts.PACKET_SIZES {
+		// extend every packet with a header or a trailer
+		stream := make([]byte, 0)
+		for i := 0; i < len(data); i += packet.PacketSize {
+			extra := bytes.Repeat([]byte{0x12}, size-packet.PacketSize)
+			if ts.SyncByteOffset(size) > 0 {
+				stream = append(stream, extra...)
+				stream = append(stream, data[i:i+packet.PacketSize]...)
+			} else {
+				stream = append(stream, data[i:i+packet.PacketSize]...)
+				stream = append(stream, extra...)
+			}
+		}
+		for _, expected := range []int{0, size} {
+			offsets, stats, detected := syncSizedPackets(stream[10:], 1000, expected)
+			if detected != size || len(offsets) != 19 || offsets[0] != int64(size-10) {
+				t.Errorf("size %v: expected 19 packets of %v bytes from %v, but get %v packets of %v bytes from %v, stats %+v\n",
+					expected, size, size-10, len(offsets), detected, offsets, stats)
+			}
+		}
+	}
+}